@@ -0,0 +1,140 @@
+package authentication
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const argon2idPrefix = "$argon2id$"
+
+const (
+	maxArgon2Memory     = 1024 * 1024
+	maxArgon2Iterations = 64
+	minArgon2SaltLength = 8
+	maxArgon2SaltLength = 64
+	minArgon2KeyLength  = 16
+	maxArgon2KeyLength  = 64
+)
+
+var ErrInvalidArgon2Params = fmt.Errorf("Invalid argon2id parameters")
+
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+var argon2Slots = make(chan struct{}, runtime.GOMAXPROCS(0))
+
+func argon2Key(password string, salt []byte, params Argon2Params) []byte {
+	argon2Slots <- struct{}{}
+	defer func() { <-argon2Slots }()
+	return argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+}
+
+type Argon2idHasher struct {
+	params Argon2Params
+}
+
+func NewArgon2idHasher(params Argon2Params) *Argon2idHasher {
+	return &Argon2idHasher{params: params}
+}
+
+func (P Argon2Params) validate() error {
+	switch {
+	case P.Iterations == 0 || P.Iterations > maxArgon2Iterations:
+		return fmt.Errorf("%w: iterations must be between 1 and %d", ErrInvalidArgon2Params, maxArgon2Iterations)
+	case P.Parallelism == 0:
+		return fmt.Errorf("%w: parallelism must be at least 1", ErrInvalidArgon2Params)
+	case P.Memory < 8*uint32(P.Parallelism) || P.Memory > maxArgon2Memory:
+		return fmt.Errorf("%w: memory must be between %d and %d KiB", ErrInvalidArgon2Params, 8*uint32(P.Parallelism), maxArgon2Memory)
+	case P.SaltLength < minArgon2SaltLength || P.SaltLength > maxArgon2SaltLength:
+		return fmt.Errorf("%w: salt length must be between %d and %d", ErrInvalidArgon2Params, minArgon2SaltLength, maxArgon2SaltLength)
+	case P.KeyLength < minArgon2KeyLength || P.KeyLength > maxArgon2KeyLength:
+		return fmt.Errorf("%w: key length must be between %d and %d", ErrInvalidArgon2Params, minArgon2KeyLength, maxArgon2KeyLength)
+	}
+	return nil
+}
+
+func (A *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, A.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2Key(password, salt, A.params)
+	return fmt.Sprintf(
+		"%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix, argon2.Version, A.params.Memory, A.params.Iterations, A.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func decodeArgon2id(hash string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	params.SaltLength, params.KeyLength = uint32(len(salt)), uint32(len(key))
+	if params.validate() != nil {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+	return params, salt, key, nil
+}
+
+func (A *Argon2idHasher) Verify(hash string, password string) error {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return err
+	}
+
+	candidate := argon2Key(password, salt, params)
+	if subtle.ConstantTimeCompare(key, candidate) != 1 {
+		return ErrPasswordMismatch
+	}
+	return nil
+}
+
+func (A *Argon2idHasher) Identifies(hash string) bool {
+	return strings.HasPrefix(hash, argon2idPrefix)
+}
+
+func (A *Argon2idHasher) NeedsRehash(hash string) bool {
+	params, _, _, err := decodeArgon2id(hash)
+	return err != nil || params != A.params
+}
@@ -0,0 +1,51 @@
+package authentication
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const breachedPrefixLength = 5
+
+type BreachedPasswords struct {
+	directory string
+}
+
+func NewBreachedPasswords(directory string) (*BreachedPasswords, error) {
+	if _, err := os.Stat(directory); err != nil {
+		return nil, err
+	}
+	return &BreachedPasswords{directory: directory}, nil
+}
+
+func (B *BreachedPasswords) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := digest[:breachedPrefixLength], digest[breachedPrefixLength:]
+
+	file, err := os.Open(filepath.Join(B.directory, prefix))
+	if errors.Is(err, os.ErrNotExist) {
+		file, err = os.Open(filepath.Join(B.directory, prefix+".txt"))
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		candidate, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(candidate, suffix) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
@@ -0,0 +1,18 @@
+package authentication
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+var ErrInvalidEmail = fmt.Errorf("Invalid email address")
+var ErrEmailTaken = fmt.Errorf("Email address already in use")
+
+func NormalizeEmail(email string) (string, error) {
+	parsed, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil {
+		return "", ErrInvalidEmail
+	}
+	return strings.ToLower(parsed.Address), nil
+}
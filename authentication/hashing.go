@@ -1,18 +1,81 @@
 package authentication
 
 import (
-	"golang.org/x/crypto/bcrypt"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
 )
 
+var ErrPasswordMismatch = fmt.Errorf("Password does not match")
+var ErrUnknownHashFormat = fmt.Errorf("Unknown hash format")
+var ErrUnknownHashAlgorithm = fmt.Errorf("Unknown hash algorithm")
+
+type Hasher interface {
+	Hash(password string) (string, error)
+	Verify(hash string, password string) error
+	Identifies(hash string) bool
+	NeedsRehash(hash string) bool
+}
+
+var currentHasher Hasher = NewArgon2idHasher(DefaultArgon2Params)
+
+var knownHashers = []Hasher{
+	NewArgon2idHasher(DefaultArgon2Params),
+	NewBcryptHasher(DefaultBcryptCost),
+}
+
+func NewHasher(algorithm string, argon2Params Argon2Params, bcryptCost int) (Hasher, error) {
+	switch strings.ToLower(algorithm) {
+	case "", "argon2id":
+		if err := argon2Params.validate(); err != nil {
+			return nil, err
+		}
+		return NewArgon2idHasher(argon2Params), nil
+	case "bcrypt":
+		return NewBcryptHasher(bcryptCost), nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownHashAlgorithm, algorithm)
+}
+
+func SetHasher(hasher Hasher) {
+	currentHasher = hasher
+}
+
+func hasherFor(hash string) (Hasher, error) {
+	if currentHasher.Identifies(hash) {
+		return currentHasher, nil
+	}
+	for _, hasher := range knownHashers {
+		if hasher.Identifies(hash) {
+			return hasher, nil
+		}
+	}
+	return nil, ErrUnknownHashFormat
+}
+
 func GenerateHash(password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return currentHasher.Hash(password)
+}
+
+func ValidateHash(hash string, password string) error {
+	hasher, err := hasherFor(hash)
 	if err != nil {
-		return "", err
+		return err
 	}
+	return hasher.Verify(hash, password)
+}
 
-	return string(hash), nil
+func NeedsRehash(hash string) bool {
+	return !currentHasher.Identifies(hash) || currentHasher.NeedsRehash(hash)
 }
 
-func ValidateHash(hash string, password string) (error) {
-	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-}
\ No newline at end of file
+func HashFingerprint(hash string) string {
+	sum := sha256.Sum256([]byte(hash))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func MatchesFingerprint(hash string, fingerprint string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashFingerprint(hash)), []byte(fingerprint)) == 1
+}
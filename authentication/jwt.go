@@ -1,8 +1,11 @@
 package authentication
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
 	"time"
@@ -11,6 +14,16 @@ import (
 var ErrInvalidToken = fmt.Errorf("Invalid token")
 var ErrInvalidSigning = fmt.Errorf("Invalid signing method")
 var ErrInvalidTokenType = fmt.Errorf("Invalid token type")
+var ErrSessionRevoked = fmt.Errorf("Session revoked")
+var ErrSessionNotFound = fmt.Errorf("Session not found")
+var ErrTokenAlreadyUsed = fmt.Errorf("Token already used")
+var ErrEmailNotFound = fmt.Errorf("Email not found")
+
+const (
+	TokenTypeMFA           = "mfa"
+	TokenTypeVerifyEmail   = "verify_email"
+	TokenTypePasswordReset = "password_reset"
+)
 
 type JWTPair struct {
 	AccessJWT  string    `json:"access_jwt"`
@@ -18,10 +31,23 @@ type JWTPair struct {
 	ExpiresAt  time.Time `json:"expires_at"`
 }
 
+type Session struct {
+	ID         string    `json:"id"`
+	Device     string    `json:"device"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Current    bool      `json:"current"`
+}
+
 type JWTClaims struct {
 	Uuid      string `json:"uuid"`
 	Username  string `json:"username"`
 	TokenType string `json:"token_type"`
+	SessionID string `json:"sid,omitempty"`
+	Email     string `json:"email,omitempty"`
+	Binding   string `json:"bnd,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -40,6 +66,12 @@ func GenerateRandomSecret(byteLen int) (string, error) {
 	return hex.EncodeToString(b), nil
 }
 
+func DeriveKey(secret string, purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
 func NewJWTManager(secret string, issuer string, accessExpiry time.Duration, refreshExpiry time.Duration) *JWTManager {
 	return &JWTManager{
 		secret:             secret,
@@ -50,6 +82,10 @@ func NewJWTManager(secret string, issuer string, accessExpiry time.Duration, ref
 }
 
 func (J *JWTManager) GenerateJWTPair(uuid string, username string) (*JWTPair, error) {
+	return J.GenerateSessionJWTPair(uuid, username, "")
+}
+
+func (J *JWTManager) GenerateSessionJWTPair(uuid string, username string, sessionID string) (*JWTPair, error) {
 	currentTime := time.Now()
 	accessExpiry := currentTime.Add(J.accessTokenExpiry)
 
@@ -57,6 +93,7 @@ func (J *JWTManager) GenerateJWTPair(uuid string, username string) (*JWTPair, er
 		Uuid:      uuid,
 		Username:  username,
 		TokenType: "access",
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			IssuedAt:  jwt.NewNumericDate(currentTime),
 			ExpiresAt: jwt.NewNumericDate(accessExpiry),
@@ -74,6 +111,7 @@ func (J *JWTManager) GenerateJWTPair(uuid string, username string) (*JWTPair, er
 		Uuid:      uuid,
 		Username:  username,
 		TokenType: "refresh",
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			IssuedAt:  jwt.NewNumericDate(currentTime),
 			ExpiresAt: jwt.NewNumericDate(currentTime.Add(J.refreshTokenExpiry)),
@@ -114,7 +152,75 @@ func (J *JWTManager) ValidateJWT(jwtStr string) (*JWTClaims, error) {
 	return claims, nil
 }
 
-func (J *JWTManager) RefreshToken(refreshStr string) (*JWTPair, error) {
+func ValidationFailureReason(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return "expired"
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return "not_yet_valid"
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return "malformed"
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid):
+		return "invalid_signature"
+	case errors.Is(err, ErrInvalidSigning):
+		return "invalid_signing_method"
+	case errors.Is(err, ErrInvalidTokenType):
+		return "invalid_token_type"
+	case errors.Is(err, ErrSessionRevoked):
+		return "session_revoked"
+	default:
+		return "invalid"
+	}
+}
+
+func (J *JWTManager) GenerateActionToken(username string, purpose string, email string, binding string, expiry time.Duration) (string, error) {
+	nonce, err := GenerateRandomSecret(16)
+	if err != nil {
+		return "", err
+	}
+
+	currentTime := time.Now()
+	claims := JWTClaims{
+		Uuid:      username,
+		Username:  username,
+		TokenType: purpose,
+		Email:     email,
+		Binding:   binding,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        nonce,
+			IssuedAt:  jwt.NewNumericDate(currentTime),
+			ExpiresAt: jwt.NewNumericDate(currentTime.Add(expiry)),
+			Issuer:    J.issuer,
+		},
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(J.secret))
+}
+
+func (J *JWTManager) ValidateActionToken(tokenStr string, purpose string) (*JWTClaims, error) {
+	claims, err := J.ValidateJWT(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.TokenType != purpose {
+		return nil, ErrInvalidTokenType
+	}
+
+	return claims, nil
+}
+
+func (J *JWTManager) GenerateMFAToken(username string, expiry time.Duration) (string, error) {
+	return J.GenerateActionToken(username, TokenTypeMFA, "", "", expiry)
+}
+
+func (J *JWTManager) ValidateMFAToken(mfaStr string) (*JWTClaims, error) {
+	return J.ValidateActionToken(mfaStr, TokenTypeMFA)
+}
+
+func (J *JWTManager) ValidateRefreshJWT(refreshStr string) (*JWTClaims, error) {
 	claims, err := J.ValidateJWT(refreshStr)
 	if err != nil {
 		return nil, err
@@ -124,5 +230,14 @@ func (J *JWTManager) RefreshToken(refreshStr string) (*JWTPair, error) {
 		return nil, ErrInvalidTokenType
 	}
 
-	return J.GenerateJWTPair(claims.Uuid, claims.Username)
+	return claims, nil
+}
+
+func (J *JWTManager) RefreshToken(refreshStr string) (*JWTPair, error) {
+	claims, err := J.ValidateRefreshJWT(refreshStr)
+	if err != nil {
+		return nil, err
+	}
+
+	return J.GenerateSessionJWTPair(claims.Uuid, claims.Username, claims.SessionID)
 }
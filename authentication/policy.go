@@ -0,0 +1,141 @@
+package authentication
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type ValidationError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type ValidationErrors []ValidationError
+
+func (V ValidationErrors) Error() string {
+	messages := make([]string, len(V))
+	for i, err := range V {
+		messages[i] = fmt.Sprintf("%s: %s", err.Field, err.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+type PasswordPolicy struct {
+	MinLength         int
+	MaxLength         int
+	RequireUppercase  bool
+	RequireLowercase  bool
+	RequireDigit      bool
+	RequireSymbol     bool
+	MinStrengthScore  int
+	ForbidUsername    bool
+	MinUsernameLength int
+	MaxUsernameLength int
+	UsernamePattern   *regexp.Regexp
+	Breached          *BreachedPasswords
+}
+
+func (P *PasswordPolicy) Validate(username string, password string) error {
+	return P.validate(username, password, true)
+}
+
+func (P *PasswordPolicy) ValidatePassword(username string, password string) error {
+	return P.validate(username, password, false)
+}
+
+func (P *PasswordPolicy) ValidateUsername(username string) error {
+	if failures := P.usernameFailures(username); len(failures) > 0 {
+		return failures
+	}
+	return nil
+}
+
+func (P *PasswordPolicy) usernameFailures(username string) ValidationErrors {
+	var failures ValidationErrors
+	add := func(code string, format string, args ...any) {
+		failures = append(failures, ValidationError{Field: "username", Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	usernameLength := utf8.RuneCountInString(username)
+	if usernameLength < P.MinUsernameLength {
+		add("too_short", "must be at least %d characters", P.MinUsernameLength)
+	}
+	if P.MaxUsernameLength > 0 && usernameLength > P.MaxUsernameLength {
+		add("too_long", "must be at most %d characters", P.MaxUsernameLength)
+	}
+	if P.UsernamePattern != nil && !P.UsernamePattern.MatchString(username) {
+		add("invalid_format", "contains characters that are not allowed")
+	}
+	return failures
+}
+
+func (P *PasswordPolicy) validate(username string, password string, checkUsername bool) error {
+	var failures ValidationErrors
+	if checkUsername {
+		failures = P.usernameFailures(username)
+	}
+	add := func(field string, code string, format string, args ...any) {
+		failures = append(failures, ValidationError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	passwordLength := utf8.RuneCountInString(password)
+	if passwordLength < P.MinLength {
+		add("password", "too_short", "must be at least %d characters", P.MinLength)
+	}
+	if P.MaxLength > 0 && passwordLength > P.MaxLength {
+		add("password", "too_long", "must be at most %d characters", P.MaxLength)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	if P.RequireUppercase && !upper {
+		add("password", "missing_uppercase", "must contain an uppercase letter")
+	}
+	if P.RequireLowercase && !lower {
+		add("password", "missing_lowercase", "must contain a lowercase letter")
+	}
+	if P.RequireDigit && !digit {
+		add("password", "missing_digit", "must contain a digit")
+	}
+	if P.RequireSymbol && !symbol {
+		add("password", "missing_symbol", "must contain a symbol")
+	}
+
+	if P.ForbidUsername && username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		add("password", "contains_username", "must not contain the username")
+	}
+
+	if score := PasswordStrength(password); score < P.MinStrengthScore {
+		add("password", "too_weak", "strength score %d is below the required %d", score, P.MinStrengthScore)
+	}
+
+	if P.Breached != nil {
+		breached, err := P.Breached.Contains(password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			add("password", "breached", "appears in a known data breach")
+		}
+	}
+
+	if len(failures) > 0 {
+		return failures
+	}
+	return nil
+}
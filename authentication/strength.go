@@ -0,0 +1,83 @@
+package authentication
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+var commonPasswordFragments = []string{
+	"password", "passw0rd", "qwerty", "azerty", "letmein", "welcome", "admin",
+	"login", "dragon", "monkey", "master", "iloveyou", "abc123", "123456",
+}
+
+func sequenceLength(password []rune) int {
+	penalty := 0
+	for i := 2; i < len(password); i++ {
+		first, second := password[i-1]-password[i-2], password[i]-password[i-1]
+		if first == second && (first == 0 || first == 1 || first == -1) {
+			penalty++
+		}
+	}
+	return penalty
+}
+
+func PasswordStrength(password string) int {
+	runes := []rune(password)
+	if len(runes) == 0 {
+		return 0
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range runes {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	alphabet := 0
+	if upper {
+		alphabet += 26
+	}
+	if lower {
+		alphabet += 26
+	}
+	if digit {
+		alphabet += 10
+	}
+	if symbol {
+		alphabet += 33
+	}
+
+	effectiveLength := len(runes) - sequenceLength(runes)
+	lowered := strings.ToLower(password)
+	for _, fragment := range commonPasswordFragments {
+		if strings.Contains(lowered, fragment) {
+			effectiveLength -= len(fragment) - 1
+		}
+	}
+	if effectiveLength < 1 {
+		effectiveLength = 1
+	}
+
+	guessesLog10 := float64(effectiveLength) * math.Log10(float64(alphabet))
+	switch {
+	case guessesLog10 < 3:
+		return 0
+	case guessesLog10 < 6:
+		return 1
+	case guessesLog10 < 8:
+		return 2
+	case guessesLog10 < 10:
+		return 3
+	default:
+		return 4
+	}
+}
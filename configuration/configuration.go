@@ -6,21 +6,75 @@ import (
 
 	"github.com/BurntSushi/toml"
 	"github.com/IzomSoftware/GinWrapper/authentication"
+	"github.com/IzomSoftware/GinWrapper/logger"
 )
 
-type TlsConfiguration struct {
-	Enable   bool   `toml:"enable"`
+type TlsCertificate struct {
 	CertFile string `toml:"cert_file"`
 	KeyFile  string `toml:"key_file"`
 }
 
+type HSTS struct {
+	Enabled           bool `toml:"enabled"`
+	MaxAgeSeconds     int  `toml:"max_age_seconds"`
+	IncludeSubdomains bool `toml:"include_subdomains"`
+	Preload           bool `toml:"preload"`
+}
+
+type ACME struct {
+	Enabled         bool     `toml:"enabled"`
+	DirectoryURL    string   `toml:"directory_url"`
+	Email           string   `toml:"email"`
+	Domains         []string `toml:"domains"`
+	CacheBackend    string   `toml:"cache_backend"`
+	CACertFile      string   `toml:"ca_cert_file"`
+	RenewBeforeDays int      `toml:"renew_before_days"`
+}
+
+type TlsConfiguration struct {
+	Enable                bool             `toml:"enable"`
+	CertFile              string           `toml:"cert_file"`
+	KeyFile               string           `toml:"key_file"`
+	Certificates          []TlsCertificate `toml:"certificates"`
+	MinVersion            string           `toml:"min_version"`
+	CipherSuites          []string         `toml:"cipher_suites"`
+	Curves                []string         `toml:"curves"`
+	ReloadIntervalSeconds int              `toml:"reload_interval_seconds"`
+	ClientCAFile          string           `toml:"client_ca_file"`
+	ClientAuth            string           `toml:"client_auth"`
+	ClientIdentitySource  string           `toml:"client_identity_source"`
+	HSTS                  HSTS             `toml:"hsts"`
+	ACME                  ACME             `toml:"acme"`
+}
+
+type Listener struct {
+	Network           string `toml:"network"`
+	Address           string `toml:"address"`
+	Role              string `toml:"role"`
+	TLS               bool   `toml:"tls"`
+	SocketPermissions uint32 `toml:"socket_permissions"`
+	RedirectPort      int    `toml:"redirect_port"`
+	H2C               bool   `toml:"h2c"`
+	HTTP3             bool   `toml:"http3"`
+}
+
 type HTTPServer struct {
-	Enabled          bool             `toml:"enabled"`
-	Address          string           `toml:"address"`
-	Port             int              `toml:"port"`
-	TemplatesDir     string           `toml:"template_dir"`
-	AssetsDir        string           `toml:"assets_dir"`
-	TlsConfiguration TlsConfiguration `toml:"tls_configuration"`
+	Enabled                bool             `toml:"enabled"`
+	Address                string           `toml:"address"`
+	Port                   int              `toml:"port"`
+	TemplatesDir           string           `toml:"template_dir"`
+	AssetsDir              string           `toml:"assets_dir"`
+	DrainDelaySeconds      int              `toml:"drain_delay_seconds"`
+	ShutdownTimeoutSeconds int              `toml:"shutdown_timeout_seconds"`
+	TlsConfiguration       TlsConfiguration `toml:"tls_configuration"`
+	Listeners              []Listener       `toml:"listeners"`
+}
+
+type Health struct {
+	Enabled             bool   `toml:"enabled"`
+	LivenessPath        string `toml:"liveness_path"`
+	ReadinessPath       string `toml:"readiness_path"`
+	CheckTimeoutSeconds int    `toml:"check_timeout_seconds"`
 }
 
 type SQLiteConfiguration struct {
@@ -113,32 +167,301 @@ type OrderingProtection struct {
 	Orders  map[string][]string `toml:"orders"`
 }
 
+type CORSPolicy struct {
+	AllowedOrigins   []string `toml:"allowed_origins"`
+	AllowedMethods   []string `toml:"allowed_methods"`
+	AllowedHeaders   []string `toml:"allowed_headers"`
+	ExposedHeaders   []string `toml:"exposed_headers"`
+	AllowCredentials bool     `toml:"allow_credentials"`
+	MaxAgeSeconds    int      `toml:"max_age_seconds"`
+}
+
+type CORSProtection struct {
+	Enabled bool                  `toml:"enabled"`
+	Default CORSPolicy            `toml:"default"`
+	Groups  map[string]CORSPolicy `toml:"groups"`
+}
+
+type SecurityHeaders struct {
+	Enabled                 bool   `toml:"enabled"`
+	ContentSecurityPolicy   string `toml:"content_security_policy"`
+	CSPReportOnly           bool   `toml:"csp_report_only"`
+	CSPReportPath           string `toml:"csp_report_path"`
+	FrameOptions            string `toml:"frame_options"`
+	ReferrerPolicy          string `toml:"referrer_policy"`
+	PermissionsPolicy       string `toml:"permissions_policy"`
+	ContentTypeNosniff      bool   `toml:"content_type_nosniff"`
+	CrossOriginOpenerPolicy string `toml:"cross_origin_opener_policy"`
+}
+
+type CSRFProtection struct {
+	Enabled         bool     `toml:"enabled"`
+	Mode            string   `toml:"mode"`
+	CookieName      string   `toml:"cookie_name"`
+	HeaderName      string   `toml:"header_name"`
+	FieldName       string   `toml:"field_name"`
+	TokenTTLSeconds int      `toml:"token_ttl_seconds"`
+	SecureCookie    bool     `toml:"secure_cookie"`
+	ExemptBearer    bool     `toml:"exempt_bearer"`
+	ExemptPaths     []string `toml:"exempt_paths"`
+}
+
+type Argon2Parameters struct {
+	MemoryKiB   uint32 `toml:"memory_kib"`
+	Iterations  uint32 `toml:"iterations"`
+	Parallelism uint8  `toml:"parallelism"`
+	SaltLength  uint32 `toml:"salt_length"`
+	KeyLength   uint32 `toml:"key_length"`
+}
+
+type PasswordHashing struct {
+	Algorithm  string           `toml:"algorithm"`
+	Argon2     Argon2Parameters `toml:"argon2"`
+	BcryptCost int              `toml:"bcrypt_cost"`
+}
+
+type PasswordPolicy struct {
+	Enabled              bool   `toml:"enabled"`
+	MinLength            int    `toml:"min_length"`
+	MaxLength            int    `toml:"max_length"`
+	RequireUppercase     bool   `toml:"require_uppercase"`
+	RequireLowercase     bool   `toml:"require_lowercase"`
+	RequireDigit         bool   `toml:"require_digit"`
+	RequireSymbol        bool   `toml:"require_symbol"`
+	MinStrengthScore     int    `toml:"min_strength_score"`
+	ForbidUsername       bool   `toml:"forbid_username"`
+	MinUsernameLength    int    `toml:"min_username_length"`
+	MaxUsernameLength    int    `toml:"max_username_length"`
+	UsernamePattern      string `toml:"username_pattern"`
+	BreachedPasswordsDir string `toml:"breached_passwords_dir"`
+}
+
+type AccountLockout struct {
+	Enabled                bool `toml:"enabled"`
+	FailureWindowSeconds   int  `toml:"failure_window_seconds"`
+	DelayAfter             int  `toml:"delay_after"`
+	BaseDelaySeconds       int  `toml:"base_delay_seconds"`
+	MaxDelaySeconds        int  `toml:"max_delay_seconds"`
+	LockoutThreshold       int  `toml:"lockout_threshold"`
+	LockoutDurationSeconds int  `toml:"lockout_duration_seconds"`
+}
+
+type TwoFactor struct {
+	Enabled            bool   `toml:"enabled"`
+	Issuer             string `toml:"issuer"`
+	SkewSteps          int    `toml:"skew_steps"`
+	RecoveryCodes      int    `toml:"recovery_codes"`
+	MFATokenTTLSeconds int    `toml:"mfa_token_ttl_seconds"`
+	QRCodeSize         int    `toml:"qr_code_size"`
+}
+
+type Passkeys struct {
+	Enabled             bool     `toml:"enabled"`
+	RPID                string   `toml:"rp_id"`
+	RPDisplayName       string   `toml:"rp_display_name"`
+	RPOrigins           []string `toml:"rp_origins"`
+	UserVerification    string   `toml:"user_verification"`
+	ChallengeTTLSeconds int      `toml:"challenge_ttl_seconds"`
+}
+
+type OIDCProvider struct {
+	Name                string   `toml:"name"`
+	DisplayName         string   `toml:"display_name"`
+	IssuerURL           string   `toml:"issuer_url"`
+	ClientID            string   `toml:"client_id"`
+	ClientSecret        string   `toml:"client_secret"`
+	RedirectURL         string   `toml:"redirect_url"`
+	Scopes              []string `toml:"scopes"`
+	UsernameClaim       string   `toml:"username_claim"`
+	AutoCreate          bool     `toml:"auto_create"`
+	LinkByVerifiedEmail bool     `toml:"link_by_verified_email"`
+}
+
+type OIDC struct {
+	Enabled         bool           `toml:"enabled"`
+	StateTTLSeconds int            `toml:"state_ttl_seconds"`
+	CookieName      string         `toml:"cookie_name"`
+	SecureCookie    bool           `toml:"secure_cookie"`
+	Providers       []OIDCProvider `toml:"providers"`
+}
+
 type Protections struct {
 	APIUserAgent        string              `toml:"api_user_agent_protection"`
 	RateLimitProtection RateLimitProtection `toml:"rate_limit_protection"`
 	JWTProtection       JWTProtection       `toml:"jwt_protection"`
 	OrderingProtection  OrderingProtection  `toml:"ordering_protection"`
+	CORSProtection      CORSProtection      `toml:"cors_protection"`
+	SecurityHeaders     SecurityHeaders     `toml:"security_headers"`
+	CSRFProtection      CSRFProtection      `toml:"csrf_protection"`
+	PasswordHashing     PasswordHashing     `toml:"password_hashing"`
+	PasswordPolicy      PasswordPolicy      `toml:"password_policy"`
+	AccountLockout      AccountLockout      `toml:"account_lockout"`
+	TwoFactor           TwoFactor           `toml:"two_factor"`
+	Passkeys            Passkeys            `toml:"passkeys"`
+	OIDC                OIDC                `toml:"oidc"`
+}
+
+type LogFile struct {
+	Path                    string `toml:"path"`
+	MaxSizeMB               int    `toml:"max_size_mb"`
+	RotationIntervalSeconds int    `toml:"rotation_interval_seconds"`
+	MaxAgeDays              int    `toml:"max_age_days"`
+	MaxBackups              int    `toml:"max_backups"`
+	Compress                bool   `toml:"compress"`
+}
+
+type LogRedaction struct {
+	Enabled bool     `toml:"enabled"`
+	Keys    []string `toml:"keys"`
+	Values  []string `toml:"values"`
+	MaskIPs bool     `toml:"mask_ips"`
+}
+
+type Logging struct {
+	Level     string            `toml:"level"`
+	Levels    map[string]string `toml:"levels"`
+	Output    string            `toml:"output"`
+	Format    string            `toml:"format"`
+	File      LogFile           `toml:"file"`
+	Redaction LogRedaction      `toml:"redaction"`
+}
+
+type Metrics struct {
+	Enabled bool   `toml:"enabled"`
+	Path    string `toml:"path"`
+	Address string `toml:"address"`
+	Port    int    `toml:"port"`
+}
+
+type Tracing struct {
+	Enabled     bool              `toml:"enabled"`
+	ServiceName string            `toml:"service_name"`
+	Exporter    string            `toml:"exporter"`
+	Endpoint    string            `toml:"endpoint"`
+	Insecure    bool              `toml:"insecure"`
+	Headers     map[string]string `toml:"headers"`
+	SampleRatio float64           `toml:"sample_ratio"`
+}
+
+type Admin struct {
+	Enabled           bool   `toml:"enabled"`
+	Address           string `toml:"address"`
+	Port              int    `toml:"port"`
+	Socket            string `toml:"socket"`
+	SocketPermissions uint32 `toml:"socket_permissions"`
+	Token             string `toml:"token"`
+	Pprof             bool   `toml:"pprof"`
+}
+
+type Sessions struct {
+	Enabled                bool   `toml:"enabled"`
+	CookieName             string `toml:"cookie_name"`
+	Secret                 string `toml:"secret"`
+	Domain                 string `toml:"domain"`
+	Path                   string `toml:"path"`
+	SecureCookie           bool   `toml:"secure_cookie"`
+	SameSite               string `toml:"same_site"`
+	IdleTimeoutSeconds     int    `toml:"idle_timeout_seconds"`
+	AbsoluteTimeoutSeconds int    `toml:"absolute_timeout_seconds"`
+}
+
+type SMTP struct {
+	Host                string `toml:"host"`
+	Port                int    `toml:"port"`
+	Username            string `toml:"username"`
+	Password            string `toml:"password"`
+	TLSMode             string `toml:"tls_mode"`
+	SkipTLSVerification bool   `toml:"skip_tls_verification"`
+	TimeoutSeconds      int    `toml:"timeout_seconds"`
+}
+
+type Mail struct {
+	Enabled               bool   `toml:"enabled"`
+	Driver                string `toml:"driver"`
+	From                  string `toml:"from"`
+	BaseURL               string `toml:"base_url"`
+	VerifyPath            string `toml:"verify_path"`
+	ResetPath             string `toml:"reset_path"`
+	TemplatesDir          string `toml:"templates_dir"`
+	Directory             string `toml:"directory"`
+	VerifyTokenTTLSeconds int    `toml:"verify_token_ttl_seconds"`
+	ResetTokenTTLSeconds  int    `toml:"reset_token_ttl_seconds"`
+	SMTP                  SMTP   `toml:"smtp"`
 }
 
 type Config struct {
 	Debug                 bool                  `toml:"debug"`
+	Logging               Logging               `toml:"logging"`
 	HTTPServer            HTTPServer            `toml:"http_server"`
 	DatabaseConfiguration DatabaseConfiguration `toml:"database"`
 	Protections           Protections           `toml:"protections"`
+	Metrics               Metrics               `toml:"metrics"`
+	Tracing               Tracing               `toml:"tracing"`
+	Health                Health                `toml:"health"`
+	Admin                 Admin                 `toml:"admin"`
+	Sessions              Sessions              `toml:"sessions"`
+	Mail                  Mail                  `toml:"mail"`
 }
 
 var Default = Config{
-	Debug: true,
+	Debug: false,
+	Logging: Logging{
+		Level: "info",
+		Levels: map[string]string{
+			"storage":    "info",
+			"auth":       "info",
+			"middleware": "info",
+		},
+		Output: "stdout",
+		Format: "text",
+		File: LogFile{
+			Path:                    "logs/ginwrapper.log",
+			MaxSizeMB:               100,
+			RotationIntervalSeconds: 86400,
+			MaxAgeDays:              30,
+			MaxBackups:              10,
+			Compress:                true,
+		},
+		Redaction: LogRedaction{
+			Enabled: true,
+			Keys:    logger.DefaultRedactedKeys,
+			Values:  logger.DefaultRedactedValues,
+			MaskIPs: false,
+		},
+	},
 	HTTPServer: HTTPServer{
-		Enabled:      true,
-		Address:      "0.0.0.0",
-		Port:         2009,
-		TemplatesDir: "./assets/templates/",
-		AssetsDir:    "./assets/",
+		Enabled:                true,
+		Address:                "0.0.0.0",
+		Port:                   2009,
+		TemplatesDir:           "./assets/templates/",
+		AssetsDir:              "./assets/",
+		DrainDelaySeconds:      5,
+		ShutdownTimeoutSeconds: 15,
 		TlsConfiguration: TlsConfiguration{
-			Enable:   false,
-			CertFile: "cert.pem",
-			KeyFile:  "key.pem",
+			Enable:                false,
+			CertFile:              "cert.pem",
+			KeyFile:               "key.pem",
+			MinVersion:            "1.2",
+			Curves:                []string{"X25519", "P256"},
+			ReloadIntervalSeconds: 60,
+			ClientCAFile:          "",
+			ClientAuth:            "none",
+			ClientIdentitySource:  "common_name",
+			HSTS: HSTS{
+				Enabled:           true,
+				MaxAgeSeconds:     31536000,
+				IncludeSubdomains: false,
+				Preload:           false,
+			},
+			ACME: ACME{
+				Enabled:         false,
+				DirectoryURL:    "https://acme-v02.api.letsencrypt.org/directory",
+				Email:           "",
+				Domains:         []string{},
+				CacheBackend:    "sql",
+				CACertFile:      "",
+				RenewBeforeDays: 30,
+			},
 		},
 	},
 	DatabaseConfiguration: DatabaseConfiguration{
@@ -211,15 +534,180 @@ var Default = Config{
 			JWTSecret:     "",
 			JWTExpiration: 60,
 		},
+		CORSProtection: CORSProtection{
+			Enabled: false,
+			Default: CORSPolicy{
+				AllowedOrigins:   []string{},
+				AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+				AllowedHeaders:   []string{"Authorization", "Content-Type", "X-Request-ID"},
+				ExposedHeaders:   []string{"X-Request-ID"},
+				AllowCredentials: false,
+				MaxAgeSeconds:    600,
+			},
+			Groups: map[string]CORSPolicy{},
+		},
+		SecurityHeaders: SecurityHeaders{
+			Enabled:                 true,
+			ContentSecurityPolicy:   "default-src 'self'; script-src 'self' 'nonce-{nonce}'; style-src 'self' 'nonce-{nonce}'; object-src 'none'; base-uri 'self'; frame-ancestors 'none'",
+			CSPReportOnly:           false,
+			CSPReportPath:           "/api/csp-report",
+			FrameOptions:            "DENY",
+			ReferrerPolicy:          "strict-origin-when-cross-origin",
+			PermissionsPolicy:       "camera=(), microphone=(), geolocation=()",
+			ContentTypeNosniff:      true,
+			CrossOriginOpenerPolicy: "same-origin",
+		},
+		PasswordHashing: PasswordHashing{
+			Algorithm: "argon2id",
+			Argon2: Argon2Parameters{
+				MemoryKiB:   64 * 1024,
+				Iterations:  3,
+				Parallelism: 2,
+				SaltLength:  16,
+				KeyLength:   32,
+			},
+			BcryptCost: 10,
+		},
+		PasswordPolicy: PasswordPolicy{
+			Enabled:              true,
+			MinLength:            10,
+			MaxLength:            256,
+			RequireUppercase:     false,
+			RequireLowercase:     false,
+			RequireDigit:         false,
+			RequireSymbol:        false,
+			MinStrengthScore:     3,
+			ForbidUsername:       true,
+			MinUsernameLength:    3,
+			MaxUsernameLength:    64,
+			UsernamePattern:      `^[a-zA-Z0-9._-]+$`,
+			BreachedPasswordsDir: "",
+		},
+		AccountLockout: AccountLockout{
+			Enabled:                true,
+			FailureWindowSeconds:   900,
+			DelayAfter:             3,
+			BaseDelaySeconds:       1,
+			MaxDelaySeconds:        60,
+			LockoutThreshold:       10,
+			LockoutDurationSeconds: 900,
+		},
+		TwoFactor: TwoFactor{
+			Enabled:            true,
+			Issuer:             "GinWrapper",
+			SkewSteps:          1,
+			RecoveryCodes:      10,
+			MFATokenTTLSeconds: 300,
+			QRCodeSize:         256,
+		},
+		Passkeys: Passkeys{
+			Enabled:             false,
+			RPID:                "localhost",
+			RPDisplayName:       "GinWrapper",
+			RPOrigins:           []string{"https://localhost:2009"},
+			UserVerification:    "preferred",
+			ChallengeTTLSeconds: 300,
+		},
+		OIDC: OIDC{
+			Enabled:         false,
+			StateTTLSeconds: 600,
+			CookieName:      "oidc_state",
+			SecureCookie:    true,
+			Providers:       []OIDCProvider{},
+		},
+		CSRFProtection: CSRFProtection{
+			Enabled:         false,
+			Mode:            "synchronizer",
+			CookieName:      "csrf",
+			HeaderName:      "X-CSRF-Token",
+			FieldName:       "csrf_token",
+			TokenTTLSeconds: 7200,
+			SecureCookie:    true,
+			ExemptBearer:    true,
+			ExemptPaths:     []string{"/api/csp-report"},
+		},
+	},
+	Metrics: Metrics{
+		Enabled: true,
+		Path:    "/metrics",
+		Address: "127.0.0.1",
+		Port:    0,
+	},
+	Tracing: Tracing{
+		Enabled:     false,
+		ServiceName: "GinWrapper",
+		Exporter:    "stdout",
+		Endpoint:    "localhost:4318",
+		Insecure:    true,
+		SampleRatio: 1.0,
+	},
+	Health: Health{
+		Enabled:             true,
+		LivenessPath:        "/healthz",
+		ReadinessPath:       "/readyz",
+		CheckTimeoutSeconds: 2,
+	},
+	Admin: Admin{
+		Enabled:           false,
+		Address:           "127.0.0.1",
+		Port:              2010,
+		Socket:            "",
+		SocketPermissions: 0660,
+		Token:             "",
+		Pprof:             true,
+	},
+	Sessions: Sessions{
+		Enabled:                false,
+		CookieName:             "session",
+		Secret:                 "",
+		Domain:                 "",
+		Path:                   "/",
+		SecureCookie:           true,
+		SameSite:               "lax",
+		IdleTimeoutSeconds:     1800,
+		AbsoluteTimeoutSeconds: 86400,
+	},
+	Mail: Mail{
+		Enabled:               false,
+		Driver:                "file",
+		From:                  "GinWrapper <no-reply@localhost>",
+		BaseURL:               "http://localhost:2009",
+		VerifyPath:            "/api/auth/email/verify",
+		ResetPath:             "/reset-password",
+		TemplatesDir:          "",
+		Directory:             "./mail/",
+		VerifyTokenTTLSeconds: 86400,
+		ResetTokenTTLSeconds:  3600,
+		SMTP: SMTP{
+			Host:           "localhost",
+			Port:           587,
+			TLSMode:        "starttls",
+			TimeoutSeconds: 30,
+		},
 	},
 }
 
 var ErrMultipleStorageSources = fmt.Errorf("cannot enable multiple Redis/SQL databases at once")
+var ErrAdminTokenRequired = fmt.Errorf("admin listener requires a token unless it only listens on a unix socket")
 
 func (c *Config) IsStorageConfigured() bool {
 	return (c.DatabaseConfiguration.DedicatedRedisConfiguration.Enabled || c.DatabaseConfiguration.EmbeddedRedisConfiguration.Enabled) && (c.DatabaseConfiguration.MySQLConfiguration.Enabled || c.DatabaseConfiguration.SQLiteConfiguration.Enabled || c.DatabaseConfiguration.PostgreSQLConfiguration.Enabled)
 }
 
+func (c *Config) adminUnixOnly() bool {
+	explicit := false
+	for _, listener := range c.HTTPServer.Listeners {
+		if listener.Role != "admin" {
+			continue
+		}
+		if listener.Network != "unix" {
+			return false
+		}
+		explicit = true
+	}
+	return explicit || c.Admin.Socket != ""
+}
+
 func LoadConfiguration(fileName string) (*Config, error) {
 	configuration := Default
 
@@ -250,5 +738,9 @@ func LoadConfiguration(fileName string) (*Config, error) {
 		return nil, ErrMultipleStorageSources
 	}
 
+	if configuration.Admin.Enabled && configuration.Admin.Token == "" && !configuration.adminUnixOnly() {
+		return nil, ErrAdminTokenRequired
+	}
+
 	return &configuration, nil
 }
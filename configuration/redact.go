@@ -0,0 +1,62 @@
+package configuration
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/BurntSushi/toml"
+)
+
+const redactedValue = "[REDACTED]"
+
+var sensitiveKey = regexp.MustCompile(`(?i)(password|secret|token)`)
+var sensitiveMap = regexp.MustCompile(`(?i)headers`)
+
+func (c *Config) Redacted() (map[string]any, error) {
+	var buffer bytes.Buffer
+	if err := toml.NewEncoder(&buffer).Encode(c); err != nil {
+		return nil, err
+	}
+
+	redacted := map[string]any{}
+	if _, err := toml.NewDecoder(&buffer).Decode(&redacted); err != nil {
+		return nil, err
+	}
+
+	redactMap(redacted)
+	return redacted, nil
+}
+
+func redactMap(values map[string]any) {
+	for key, value := range values {
+		if sensitiveKey.MatchString(key) {
+			if text, ok := value.(string); ok && text == "" {
+				continue
+			}
+			values[key] = redactedValue
+			continue
+		}
+		if headers, ok := value.(map[string]any); ok && sensitiveMap.MatchString(key) {
+			for name := range headers {
+				headers[name] = redactedValue
+			}
+			continue
+		}
+		redactValue(value)
+	}
+}
+
+func redactValue(value any) {
+	switch typed := value.(type) {
+	case map[string]any:
+		redactMap(typed)
+	case []map[string]any:
+		for _, item := range typed {
+			redactMap(item)
+		}
+	case []any:
+		for _, item := range typed {
+			redactValue(item)
+		}
+	}
+}
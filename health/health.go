@@ -0,0 +1,165 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	StatusOK      = "ok"
+	StatusFailing = "failing"
+)
+
+var ErrShuttingDown = fmt.Errorf("server is shutting down")
+
+type Check func(ctx context.Context) error
+
+type registeredCheck struct {
+	check   Check
+	timeout time.Duration
+}
+
+type CheckResult struct {
+	Status     string `json:"status"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+type Checker struct {
+	mutex          sync.RWMutex
+	checks         map[string]registeredCheck
+	defaultTimeout time.Duration
+	shuttingDown   atomic.Bool
+}
+
+func NewChecker(defaultTimeout time.Duration) *Checker {
+	return &Checker{
+		checks:         map[string]registeredCheck{},
+		defaultTimeout: defaultTimeout,
+	}
+}
+
+func (C *Checker) Register(name string, timeout time.Duration, check Check) {
+	if timeout <= 0 {
+		timeout = C.defaultTimeout
+	}
+
+	C.mutex.Lock()
+	defer C.mutex.Unlock()
+	C.checks[name] = registeredCheck{check: check, timeout: timeout}
+}
+
+func (C *Checker) Unregister(name string) {
+	C.mutex.Lock()
+	defer C.mutex.Unlock()
+	delete(C.checks, name)
+}
+
+func (C *Checker) SetShuttingDown() {
+	C.shuttingDown.Store(true)
+}
+
+func (C *Checker) IsShuttingDown() bool {
+	return C.shuttingDown.Load()
+}
+
+func (C *Checker) Names() []string {
+	C.mutex.RLock()
+	defer C.mutex.RUnlock()
+
+	names := make([]string, 0, len(C.checks))
+	for name := range C.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (C *Checker) Check(ctx context.Context) Report {
+	C.mutex.RLock()
+	checks := make(map[string]registeredCheck, len(C.checks))
+	for name, check := range C.checks {
+		checks[name] = check
+	}
+	C.mutex.RUnlock()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(checks)+1)}
+	if C.IsShuttingDown() {
+		report.Status = StatusFailing
+		report.Checks["shutdown"] = CheckResult{Status: StatusFailing, Error: ErrShuttingDown.Error()}
+	}
+
+	var resultsMutex sync.Mutex
+	var wait sync.WaitGroup
+	for name, check := range checks {
+		wait.Add(1)
+		go func(name string, check registeredCheck) {
+			defer wait.Done()
+			result := runCheck(ctx, check)
+
+			resultsMutex.Lock()
+			defer resultsMutex.Unlock()
+			report.Checks[name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusFailing
+			}
+		}(name, check)
+	}
+	wait.Wait()
+
+	return report
+}
+
+func runCheck(ctx context.Context, check registeredCheck) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, check.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- check.check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := CheckResult{Status: StatusOK, DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = StatusFailing
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func (C *Checker) LivenessHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, Report{Status: StatusOK})
+	}
+}
+
+func (C *Checker) ReadinessHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := C.Check(c.Request.Context())
+		status := http.StatusOK
+		if report.Status != StatusOK {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, report)
+	}
+}
@@ -0,0 +1,55 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+)
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	usernameKey
+)
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+func WithUsername(ctx context.Context, username string) context.Context {
+	return context.WithValue(ctx, usernameKey, username)
+}
+
+func Username(ctx context.Context) string {
+	username, _ := ctx.Value(usernameKey).(string)
+	return username
+}
+
+type contextHandler struct {
+	slog.Handler
+}
+
+func (H contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if ctx != nil {
+		if requestID := RequestID(ctx); requestID != "" {
+			record.AddAttrs(slog.String("request_id", requestID))
+		}
+		if username := Username(ctx); username != "" {
+			record.AddAttrs(slog.String("username", username))
+		}
+	}
+	return H.Handler.Handle(ctx, record)
+}
+
+func (H contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{H.Handler.WithAttrs(attrs)}
+}
+
+func (H contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{H.Handler.WithGroup(name)}
+}
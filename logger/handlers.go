@@ -0,0 +1,54 @@
+package logger
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type levelRequest struct {
+	Subsystem string `json:"subsystem" form:"subsystem"`
+	Level     string `json:"level" form:"level"`
+}
+
+func LevelsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"level":      GetLevel().String(),
+			"subsystems": Levels(),
+		})
+	}
+}
+
+func SetLevelHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request levelRequest
+		if err := c.ShouldBind(&request); err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		if request.Level == "" && request.Subsystem != "" {
+			ResetSubsystemLevel(request.Subsystem)
+			InfoContext(c.Request.Context(), "log level reset", "subsystem", request.Subsystem)
+			c.JSON(http.StatusOK, gin.H{"level": GetLevel().String(), "subsystems": Levels()})
+			return
+		}
+
+		level, err := ParseLevel(request.Level)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if request.Subsystem == "" {
+			SetLevel(level)
+		} else if err := SetSubsystemLevel(request.Subsystem, level); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		InfoContext(c.Request.Context(), "log level changed", "subsystem", request.Subsystem, "level", level.String())
+
+		c.JSON(http.StatusOK, gin.H{"level": GetLevel().String(), "subsystems": Levels()})
+	}
+}
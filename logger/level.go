@@ -0,0 +1,129 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var ErrInvalidLevel = fmt.Errorf("invalid log level")
+var ErrUnknownSubsystem = fmt.Errorf("unknown log subsystem")
+
+var (
+	rootLevel       = new(slog.LevelVar)
+	levelsMutex     sync.RWMutex
+	subsystemLevels = map[string]*slog.LevelVar{}
+	subsystems      = map[string]struct{}{}
+)
+
+func ParseLevel(level string) (slog.Level, error) {
+	var parsed slog.Level
+	if err := parsed.UnmarshalText([]byte(strings.TrimSpace(level))); err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidLevel, level)
+	}
+	return parsed, nil
+}
+
+func SetLevel(level slog.Level) {
+	rootLevel.Set(level)
+}
+
+func GetLevel() slog.Level {
+	return rootLevel.Level()
+}
+
+func SetSubsystemLevel(name string, level slog.Level) error {
+	levelsMutex.Lock()
+	defer levelsMutex.Unlock()
+
+	if _, ok := subsystems[name]; !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownSubsystem, name)
+	}
+	if levelVar, ok := subsystemLevels[name]; ok {
+		levelVar.Set(level)
+		return nil
+	}
+	levelVar := new(slog.LevelVar)
+	levelVar.Set(level)
+	subsystemLevels[name] = levelVar
+	return nil
+}
+
+func ResetSubsystemLevel(name string) {
+	levelsMutex.Lock()
+	defer levelsMutex.Unlock()
+	delete(subsystemLevels, name)
+}
+
+func GetSubsystemLevel(name string) slog.Level {
+	levelsMutex.RLock()
+	defer levelsMutex.RUnlock()
+
+	if levelVar, ok := subsystemLevels[name]; ok {
+		return levelVar.Level()
+	}
+	return rootLevel.Level()
+}
+
+func ApplyLevels(level string, overrides map[string]string) error {
+	if level != "" {
+		parsed, err := ParseLevel(level)
+		if err != nil {
+			return err
+		}
+		SetLevel(parsed)
+	}
+
+	for name, override := range overrides {
+		parsed, err := ParseLevel(override)
+		if err != nil {
+			return fmt.Errorf("subsystem %s: %w", name, err)
+		}
+		if err := SetSubsystemLevel(name, parsed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type SubsystemLevel struct {
+	Name       string `json:"name"`
+	Level      string `json:"level"`
+	Overridden bool   `json:"overridden"`
+}
+
+func Levels() []SubsystemLevel {
+	levelsMutex.RLock()
+	defer levelsMutex.RUnlock()
+
+	levels := make([]SubsystemLevel, 0, len(subsystems))
+	for name := range subsystems {
+		levelVar, overridden := subsystemLevels[name]
+		level := rootLevel.Level()
+		if overridden {
+			level = levelVar.Level()
+		}
+		levels = append(levels, SubsystemLevel{Name: name, Level: level.String(), Overridden: overridden})
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i].Name < levels[j].Name })
+	return levels
+}
+
+type rootLevelHandler struct {
+	slog.Handler
+}
+
+func (H rootLevelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= rootLevel.Level() && H.Handler.Enabled(ctx, level)
+}
+
+func (H rootLevelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return rootLevelHandler{H.Handler.WithAttrs(attrs)}
+}
+
+func (H rootLevelHandler) WithGroup(name string) slog.Handler {
+	return rootLevelHandler{H.Handler.WithGroup(name)}
+}
@@ -1,15 +1,31 @@
 package logger
 
 import (
+	"context"
 	"log/slog"
+	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupLogger(handler slog.Handler) {
-	slog.SetDefault(slog.New(handler))
+var handler atomic.Pointer[slog.Handler]
+
+func SetupLogger(base slog.Handler) {
+	wrapped := slog.Handler(contextHandler{base})
+	handler.Store(&wrapped)
+	slog.SetDefault(slog.New(rootLevelHandler{wrapped}))
+}
+
+func currentHandler() slog.Handler {
+	if current := handler.Load(); current != nil {
+		return *current
+	}
+	return slog.Default().Handler()
 }
 
+func Debug(msg string, args ...any) {
+	slog.Debug(msg, args...)
+}
 func Info(msg string, args ...any) {
 	slog.Info(msg, args...)
 }
@@ -19,6 +35,18 @@ func Warn(msg string, args ...any) {
 func Error(msg string, args ...any) {
 	slog.Error(msg, args...)
 }
+func DebugContext(ctx context.Context, msg string, args ...any) {
+	slog.DebugContext(ctx, msg, args...)
+}
+func InfoContext(ctx context.Context, msg string, args ...any) {
+	slog.InfoContext(ctx, msg, args...)
+}
+func WarnContext(ctx context.Context, msg string, args ...any) {
+	slog.WarnContext(ctx, msg, args...)
+}
+func ErrorContext(ctx context.Context, msg string, args ...any) {
+	slog.ErrorContext(ctx, msg, args...)
+}
 func LogConnection(connection *gin.Context) {
-	Info("connection", "ip", connection.ClientIP(), "path", connection.Request.URL.Path)
+	InfoContext(connection.Request.Context(), "connection", "ip", connection.ClientIP(), "path", connection.Request.URL.Path)
 }
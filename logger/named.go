@@ -0,0 +1,70 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"time"
+)
+
+type Logger struct {
+	name string
+}
+
+func Named(name string) *Logger {
+	levelsMutex.Lock()
+	subsystems[name] = struct{}{}
+	levelsMutex.Unlock()
+
+	return &Logger{name: name}
+}
+
+func (L *Logger) Name() string {
+	return L.name
+}
+
+func (L *Logger) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= GetSubsystemLevel(L.name) && currentHandler().Enabled(ctx, level)
+}
+
+func (L *Logger) log(ctx context.Context, level slog.Level, msg string, args ...any) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if !L.Enabled(ctx, level) {
+		return
+	}
+
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+
+	record := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	record.AddAttrs(slog.String("subsystem", L.name))
+	record.Add(args...)
+	_ = currentHandler().Handle(ctx, record)
+}
+
+func (L *Logger) Debug(msg string, args ...any) {
+	L.log(context.Background(), slog.LevelDebug, msg, args...)
+}
+func (L *Logger) Info(msg string, args ...any) {
+	L.log(context.Background(), slog.LevelInfo, msg, args...)
+}
+func (L *Logger) Warn(msg string, args ...any) {
+	L.log(context.Background(), slog.LevelWarn, msg, args...)
+}
+func (L *Logger) Error(msg string, args ...any) {
+	L.log(context.Background(), slog.LevelError, msg, args...)
+}
+func (L *Logger) DebugContext(ctx context.Context, msg string, args ...any) {
+	L.log(ctx, slog.LevelDebug, msg, args...)
+}
+func (L *Logger) InfoContext(ctx context.Context, msg string, args ...any) {
+	L.log(ctx, slog.LevelInfo, msg, args...)
+}
+func (L *Logger) WarnContext(ctx context.Context, msg string, args ...any) {
+	L.log(ctx, slog.LevelWarn, msg, args...)
+}
+func (L *Logger) ErrorContext(ctx context.Context, msg string, args ...any) {
+	L.log(ctx, slog.LevelError, msg, args...)
+}
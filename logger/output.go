@@ -0,0 +1,68 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+var ErrInvalidOutput = fmt.Errorf("invalid log output")
+var ErrInvalidFormat = fmt.Errorf("invalid log format")
+
+type Options struct {
+	Output    string
+	Format    string
+	File      FileOptions
+	Redaction *RedactionOptions
+}
+
+func NewHandler(writer io.Writer, format string) (slog.Handler, error) {
+	handlerOptions := &slog.HandlerOptions{Level: slog.LevelDebug}
+
+	switch strings.ToLower(format) {
+	case "", "text":
+		return slog.NewTextHandler(writer, handlerOptions), nil
+	case "json":
+		return slog.NewJSONHandler(writer, handlerOptions), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidFormat, format)
+	}
+}
+
+func Setup(options Options) (*RotatingFile, error) {
+	var writer io.Writer
+	var file *RotatingFile
+	var err error
+
+	switch strings.ToLower(options.Output) {
+	case "", "stdout":
+		writer = os.Stdout
+	case "file":
+		file, err = NewRotatingFile(options.File)
+		writer = file
+	case "both":
+		file, err = NewRotatingFile(options.File)
+		writer = io.MultiWriter(os.Stdout, file)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidOutput, options.Output)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	handler, err := NewHandler(writer, options.Format)
+	if err == nil && options.Redaction != nil {
+		handler, err = NewRedactingHandler(handler, *options.Redaction)
+	}
+	if err != nil {
+		if file != nil {
+			file.Close()
+		}
+		return nil, err
+	}
+
+	SetupLogger(handler)
+	return file, nil
+}
@@ -0,0 +1,196 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"regexp"
+)
+
+const redacted = "[REDACTED]"
+
+var DefaultRedactedKeys = []string{
+	"password",
+	"passwd",
+	"secret",
+	"token$",
+	"jwt$",
+	"authorization",
+	"cookie",
+	"api_?key",
+}
+
+var DefaultRedactedValues = []string{
+	`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`,
+	`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`,
+}
+
+type RedactionOptions struct {
+	Keys    []string
+	Values  []string
+	MaskIPs bool
+}
+
+type redactor struct {
+	keys    []*regexp.Regexp
+	values  []*regexp.Regexp
+	maskIPs bool
+}
+
+type redactingHandler struct {
+	slog.Handler
+	redactor *redactor
+}
+
+func NewRedactingHandler(handler slog.Handler, options RedactionOptions) (slog.Handler, error) {
+	redactor := &redactor{maskIPs: options.MaskIPs}
+
+	for _, pattern := range options.Keys {
+		compiled, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redaction key pattern %q: %w", pattern, err)
+		}
+		redactor.keys = append(redactor.keys, compiled)
+	}
+
+	for _, pattern := range options.Values {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redaction value pattern %q: %w", pattern, err)
+		}
+		redactor.values = append(redactor.values, compiled)
+	}
+
+	return redactingHandler{Handler: handler, redactor: redactor}, nil
+}
+
+func (H redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	redactedRecord := slog.NewRecord(record.Time, record.Level, H.redactor.redactString(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redactedRecord.AddAttrs(H.redactor.redactAttr(attr))
+		return true
+	})
+	return H.Handler.Handle(ctx, redactedRecord)
+}
+
+func (H redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redactedAttrs := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redactedAttrs[i] = H.redactor.redactAttr(attr)
+	}
+	return redactingHandler{Handler: H.Handler.WithAttrs(redactedAttrs), redactor: H.redactor}
+}
+
+func (H redactingHandler) WithGroup(name string) slog.Handler {
+	return redactingHandler{Handler: H.Handler.WithGroup(name), redactor: H.redactor}
+}
+
+func (R *redactor) isSensitiveKey(key string) bool {
+	for _, pattern := range R.keys {
+		if pattern.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (R *redactor) redactAttr(attr slog.Attr) slog.Attr {
+	if R.isSensitiveKey(attr.Key) {
+		return slog.String(attr.Key, redacted)
+	}
+
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, R.redactString(value.String()))
+	case slog.KindGroup:
+		groupAttrs := value.Group()
+		redactedAttrs := make([]slog.Attr, len(groupAttrs))
+		for i, groupAttr := range groupAttrs {
+			redactedAttrs[i] = R.redactAttr(groupAttr)
+		}
+		return slog.Attr{Key: attr.Key, Value: slog.GroupValue(redactedAttrs...)}
+	case slog.KindAny:
+		return slog.Any(attr.Key, R.redactAny(value.Any()))
+	default:
+		return slog.Attr{Key: attr.Key, Value: value}
+	}
+}
+
+func (R *redactor) redactAny(value any) any {
+	switch typed := value.(type) {
+	case http.Header:
+		return R.redactMultiMap(typed)
+	case url.Values:
+		return R.redactMultiMap(typed)
+	case map[string][]string:
+		return R.redactMultiMap(typed)
+	case map[string]string:
+		redactedMap := make(map[string]string, len(typed))
+		for key, item := range typed {
+			if R.isSensitiveKey(key) {
+				redactedMap[key] = redacted
+				continue
+			}
+			redactedMap[key] = R.redactString(item)
+		}
+		return redactedMap
+	case net.IP:
+		return R.redactString(typed.String())
+	case netip.Addr:
+		return R.redactString(typed.String())
+	case error:
+		return R.redactString(typed.Error())
+	case fmt.Stringer:
+		return R.redactString(typed.String())
+	default:
+		return value
+	}
+}
+
+func (R *redactor) redactMultiMap(values map[string][]string) map[string][]string {
+	redactedMap := make(map[string][]string, len(values))
+	for key, items := range values {
+		if R.isSensitiveKey(key) {
+			redactedMap[key] = []string{redacted}
+			continue
+		}
+		redactedItems := make([]string, len(items))
+		for i, item := range items {
+			redactedItems[i] = R.redactString(item)
+		}
+		redactedMap[key] = redactedItems
+	}
+	return redactedMap
+}
+
+func (R *redactor) redactString(value string) string {
+	if R.maskIPs {
+		if address, err := netip.ParseAddr(value); err == nil {
+			return maskIP(address)
+		}
+	}
+
+	for _, pattern := range R.values {
+		value = pattern.ReplaceAllString(value, redacted)
+	}
+	return value
+}
+
+func maskIP(address netip.Addr) string {
+	bits := 48
+	if address.Is4() || address.Is4In6() {
+		address = address.Unmap()
+		bits = 24
+	}
+
+	prefix, err := address.Prefix(bits)
+	if err != nil {
+		return redacted
+	}
+	return prefix.Addr().String()
+}
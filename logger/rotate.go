@@ -0,0 +1,264 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+type FileOptions struct {
+	Path             string
+	MaxSize          int64
+	RotationInterval time.Duration
+	MaxAge           time.Duration
+	MaxBackups       int
+	Compress         bool
+}
+
+type RotatingFile struct {
+	options      FileOptions
+	mutex        sync.Mutex
+	millMutex    sync.Mutex
+	file         *os.File
+	size         int64
+	nextRotation time.Time
+}
+
+func NewRotatingFile(options FileOptions) (*RotatingFile, error) {
+	if options.Path == "" {
+		return nil, fmt.Errorf("log file path is empty")
+	}
+
+	rotatingFile := &RotatingFile{options: options}
+	if err := rotatingFile.open(); err != nil {
+		return nil, err
+	}
+	return rotatingFile, nil
+}
+
+func (R *RotatingFile) Write(p []byte) (int, error) {
+	R.mutex.Lock()
+	defer R.mutex.Unlock()
+
+	if R.file == nil {
+		if err := R.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if R.shouldRotate(int64(len(p))) {
+		if err := R.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := R.file.Write(p)
+	R.size += int64(n)
+	return n, err
+}
+
+func (R *RotatingFile) Rotate() error {
+	R.mutex.Lock()
+	defer R.mutex.Unlock()
+	return R.rotate()
+}
+
+func (R *RotatingFile) Reopen() error {
+	R.mutex.Lock()
+	defer R.mutex.Unlock()
+
+	if err := R.close(); err != nil {
+		return err
+	}
+	return R.open()
+}
+
+func (R *RotatingFile) Close() error {
+	R.mutex.Lock()
+	defer R.mutex.Unlock()
+	return R.close()
+}
+
+func (R *RotatingFile) ReopenOnSignal(signals ...os.Signal) (stop func()) {
+	channel := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(channel, signals...)
+
+	go func() {
+		for {
+			select {
+			case <-channel:
+				if err := R.Reopen(); err != nil {
+					fmt.Fprintf(os.Stderr, "failed to reopen log file %s: %v\n", R.options.Path, err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(channel)
+		close(done)
+	}
+}
+
+func (R *RotatingFile) shouldRotate(writeSize int64) bool {
+	if R.options.MaxSize > 0 && R.size > 0 && R.size+writeSize > R.options.MaxSize {
+		return true
+	}
+	return R.options.RotationInterval > 0 && !time.Now().Before(R.nextRotation)
+}
+
+func (R *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(R.options.Path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(R.options.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	R.file = file
+	R.size = info.Size()
+	if R.options.RotationInterval > 0 {
+		R.nextRotation = time.Now().Truncate(R.options.RotationInterval).Add(R.options.RotationInterval)
+	}
+	return nil
+}
+
+func (R *RotatingFile) close() error {
+	if R.file == nil {
+		return nil
+	}
+	err := R.file.Close()
+	R.file = nil
+	return err
+}
+
+func (R *RotatingFile) rotate() error {
+	if err := R.close(); err != nil {
+		return err
+	}
+
+	backup := fmt.Sprintf("%s.%s", R.options.Path, time.Now().Format(backupTimeFormat))
+	if err := os.Rename(R.options.Path, backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := R.open(); err != nil {
+		return err
+	}
+
+	go R.mill(backup)
+	return nil
+}
+
+func (R *RotatingFile) mill(backup string) {
+	R.millMutex.Lock()
+	defer R.millMutex.Unlock()
+
+	if R.options.Compress {
+		if err := compressFile(backup); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to compress log file %s: %v\n", backup, err)
+		}
+	}
+
+	if err := R.removeExpiredBackups(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to clean up log files for %s: %v\n", R.options.Path, err)
+	}
+}
+
+func (R *RotatingFile) removeExpiredBackups() error {
+	if R.options.MaxAge <= 0 && R.options.MaxBackups <= 0 {
+		return nil
+	}
+
+	directory, base := filepath.Split(R.options.Path)
+	if directory == "" {
+		directory = "."
+	}
+
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return err
+	}
+
+	type backupFile struct {
+		path    string
+		modTime time.Time
+	}
+
+	var backups []backupFile
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), base+".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backupFile{path: filepath.Join(directory, entry.Name()), modTime: info.ModTime()})
+	}
+
+	sort.Slice(backups, func(i, j int) bool { return backups[i].modTime.After(backups[j].modTime) })
+
+	cutoff := time.Now().Add(-R.options.MaxAge)
+	for i, backup := range backups {
+		expired := R.options.MaxAge > 0 && backup.modTime.Before(cutoff)
+		excess := R.options.MaxBackups > 0 && i >= R.options.MaxBackups
+		if expired || excess {
+			if err := os.Remove(backup.path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func compressFile(path string) error {
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	destination, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	writer := gzip.NewWriter(destination)
+	if _, err := io.Copy(writer, source); err != nil {
+		destination.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		destination.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := destination.Close(); err != nil {
+		return err
+	}
+
+	source.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,36 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type FileMailer struct {
+	from      string
+	directory string
+}
+
+func NewFileMailer(from string, directory string) (*FileMailer, error) {
+	if err := os.MkdirAll(directory, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileMailer{from: from, directory: directory}, nil
+}
+
+func (F *FileMailer) Send(ctx context.Context, message Message) error {
+	data, err := message.Bytes(F.from)
+	if err != nil {
+		return err
+	}
+
+	name := filepath.Join(F.directory, fmt.Sprintf("%d.eml", time.Now().UnixNano()))
+	if err := os.WriteFile(name, data, 0o600); err != nil {
+		return err
+	}
+
+	log.InfoContext(ctx, "mail written", "to", message.To, "subject", message.Subject, "path", name)
+	return nil
+}
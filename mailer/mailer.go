@@ -0,0 +1,81 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"time"
+
+	"github.com/IzomSoftware/GinWrapper/authentication"
+	"github.com/IzomSoftware/GinWrapper/configuration"
+	"github.com/IzomSoftware/GinWrapper/logger"
+)
+
+var log = logger.Named("mailer")
+
+var ErrUnknownDriver = fmt.Errorf("unknown mail driver")
+
+type Message struct {
+	To       string
+	Subject  string
+	TextBody string
+	HTMLBody string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+func New(configuration configuration.Mail) (Mailer, error) {
+	switch configuration.Driver {
+	case "smtp":
+		return NewSMTPMailer(configuration.From, configuration.SMTP), nil
+	case "file":
+		return NewFileMailer(configuration.From, configuration.Directory)
+	case "memory":
+		return NewMemoryMailer(), nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownDriver, configuration.Driver)
+}
+
+func (M Message) Bytes(from string) ([]byte, error) {
+	var buffer bytes.Buffer
+	messageID, err := authentication.GenerateRandomSecret(16)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(&buffer, "From: %s\r\n", from)
+	fmt.Fprintf(&buffer, "To: %s\r\n", M.To)
+	fmt.Fprintf(&buffer, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", M.Subject))
+	fmt.Fprintf(&buffer, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buffer, "Message-ID: <%s@ginwrapper>\r\n", messageID)
+	fmt.Fprintf(&buffer, "MIME-Version: 1.0\r\n")
+
+	if M.HTMLBody == "" {
+		fmt.Fprintf(&buffer, "Content-Type: text/plain; charset=utf-8\r\n\r\n%s", M.TextBody)
+		return buffer.Bytes(), nil
+	}
+
+	writer := multipart.NewWriter(&buffer)
+	fmt.Fprintf(&buffer, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", writer.Boundary())
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", M.TextBody},
+		{"text/html; charset=utf-8", M.HTMLBody},
+	} {
+		partWriter, err := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := partWriter.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,37 @@
+package mailer
+
+import (
+	"context"
+	"sync"
+)
+
+type MemoryMailer struct {
+	mutex    sync.Mutex
+	messages []Message
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (M *MemoryMailer) Send(ctx context.Context, message Message) error {
+	M.mutex.Lock()
+	defer M.mutex.Unlock()
+
+	M.messages = append(M.messages, message)
+	return nil
+}
+
+func (M *MemoryMailer) Messages() []Message {
+	M.mutex.Lock()
+	defer M.mutex.Unlock()
+
+	return append([]Message(nil), M.messages...)
+}
+
+func (M *MemoryMailer) Reset() {
+	M.mutex.Lock()
+	defer M.mutex.Unlock()
+
+	M.messages = nil
+}
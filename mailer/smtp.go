@@ -0,0 +1,109 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"github.com/IzomSoftware/GinWrapper/configuration"
+)
+
+const defaultSMTPTimeout = 30 * time.Second
+
+type SMTPMailer struct {
+	from          string
+	configuration configuration.SMTP
+}
+
+func NewSMTPMailer(from string, configuration configuration.SMTP) *SMTPMailer {
+	return &SMTPMailer{from: from, configuration: configuration}
+}
+
+func (S *SMTPMailer) timeout() time.Duration {
+	if S.configuration.TimeoutSeconds <= 0 {
+		return defaultSMTPTimeout
+	}
+	return time.Duration(S.configuration.TimeoutSeconds) * time.Second
+}
+
+func (S *SMTPMailer) dial(ctx context.Context) (*smtp.Client, error) {
+	address := net.JoinHostPort(S.configuration.Host, strconv.Itoa(S.configuration.Port))
+	tlsConfig := &tls.Config{ServerName: S.configuration.Host, InsecureSkipVerify: S.configuration.SkipTLSVerification}
+
+	timeout := S.timeout()
+	dialer := net.Dialer{Timeout: timeout}
+	var connection net.Conn
+	var err error
+	if S.configuration.TLSMode == "tls" {
+		connection, err = (&tls.Dialer{NetDialer: &dialer, Config: tlsConfig}).DialContext(ctx, "tcp", address)
+	} else {
+		connection, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := connection.SetDeadline(time.Now().Add(timeout)); err != nil {
+		connection.Close()
+		return nil, err
+	}
+
+	client, err := smtp.NewClient(connection, S.configuration.Host)
+	if err != nil {
+		connection.Close()
+		return nil, err
+	}
+
+	if S.configuration.TLSMode == "starttls" {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+func (S *SMTPMailer) Send(ctx context.Context, message Message) error {
+	data, err := message.Bytes(S.from)
+	if err != nil {
+		return err
+	}
+
+	client, err := S.dial(ctx)
+	if err != nil {
+		return fmt.Errorf("smtp dial: %w", err)
+	}
+	defer client.Close()
+
+	if S.configuration.Username != "" {
+		auth := smtp.PlainAuth("", S.configuration.Username, S.configuration.Password, S.configuration.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+
+	if err := client.Mail(S.from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(message.To); err != nil {
+		return err
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(data); err != nil {
+		writer.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	log.InfoContext(ctx, "mail sent", "to", message.To, "subject", message.Subject)
+	return client.Quit()
+}
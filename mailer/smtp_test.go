@@ -0,0 +1,58 @@
+package mailer
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/IzomSoftware/GinWrapper/configuration"
+)
+
+func TestSMTPStalledServerTimesOut(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		var stalled []net.Conn
+		defer func() {
+			for _, connection := range stalled {
+				connection.Close()
+			}
+		}()
+		for {
+			connection, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			stalled = append(stalled, connection)
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	mailer := NewSMTPMailer("GinWrapper <no-reply@localhost>", configuration.SMTP{
+		Host:           host,
+		Port:           portNumber,
+		TLSMode:        "none",
+		TimeoutSeconds: 1,
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- mailer.Send(context.Background(), Message{To: "alice@example.com", Subject: "Hello", TextBody: "Hello"})
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("Send succeeded against a server that never answered")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Send did not give up on a stalled server")
+	}
+}
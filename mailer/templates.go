@@ -0,0 +1,100 @@
+package mailer
+
+import (
+	"bytes"
+	"errors"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+)
+
+const (
+	TemplateVerifyEmail   = "verify_email"
+	TemplatePasswordReset = "password_reset"
+)
+
+var defaultTemplates = map[string][3]string{
+	TemplateVerifyEmail: {
+		"Verify your email address",
+		"Hello {{.Username}},\n\nConfirm your email address by opening the link below:\n\n{{.Link}}\n\nThe link expires in {{.ExpiresIn}}.\n",
+		`<p>Hello {{.Username}},</p><p>Confirm your email address by opening the link below:</p><p><a href="{{.Link}}">{{.Link}}</a></p><p>The link expires in {{.ExpiresIn}}.</p>`,
+	},
+	TemplatePasswordReset: {
+		"Reset your password",
+		"Hello {{.Username}},\n\nA password reset was requested for your account. Open the link below to choose a new password:\n\n{{.Link}}\n\nThe link expires in {{.ExpiresIn}}. If you did not request this, ignore this email.\n",
+		`<p>Hello {{.Username}},</p><p>A password reset was requested for your account. Open the link below to choose a new password:</p><p><a href="{{.Link}}">{{.Link}}</a></p><p>The link expires in {{.ExpiresIn}}. If you did not request this, ignore this email.</p>`,
+	},
+}
+
+type Templates struct {
+	directory string
+}
+
+func NewTemplates(directory string) *Templates {
+	return &Templates{directory: directory}
+}
+
+func (T *Templates) source(name string, extension string, fallback string) (string, error) {
+	if T.directory == "" {
+		return fallback, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(T.directory, name+extension))
+	if errors.Is(err, os.ErrNotExist) {
+		return fallback, nil
+	}
+	return string(data), err
+}
+
+func (T *Templates) Render(name string, to string, data any) (Message, error) {
+	defaults := defaultTemplates[name]
+	message := Message{To: to}
+
+	subjectSource, err := T.source(name, ".subject.tmpl", defaults[0])
+	if err != nil {
+		return message, err
+	}
+	textSource, err := T.source(name, ".txt.tmpl", defaults[1])
+	if err != nil {
+		return message, err
+	}
+	htmlSource, err := T.source(name, ".html.tmpl", defaults[2])
+	if err != nil {
+		return message, err
+	}
+
+	var buffer bytes.Buffer
+	for _, part := range []struct {
+		source string
+		target *string
+	}{
+		{subjectSource, &message.Subject},
+		{textSource, &message.TextBody},
+	} {
+		tmpl, err := texttemplate.New(name).Parse(part.source)
+		if err != nil {
+			return message, err
+		}
+		buffer.Reset()
+		if err := tmpl.Execute(&buffer, data); err != nil {
+			return message, err
+		}
+		*part.target = buffer.String()
+	}
+	message.Subject = strings.TrimSpace(message.Subject)
+
+	if htmlSource != "" {
+		tmpl, err := htmltemplate.New(name).Parse(htmlSource)
+		if err != nil {
+			return message, err
+		}
+		buffer.Reset()
+		if err := tmpl.Execute(&buffer, data); err != nil {
+			return message, err
+		}
+		message.HTMLBody = buffer.String()
+	}
+	return message, nil
+}
@@ -1,19 +1,28 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
-	"os"
+	"net/url"
+	"regexp"
+	"syscall"
 	"time"
 
 	"github.com/IzomSoftware/GinWrapper/authentication"
 	"github.com/IzomSoftware/GinWrapper/configuration"
 	"github.com/IzomSoftware/GinWrapper/logger"
+	"github.com/IzomSoftware/GinWrapper/mailer"
 	"github.com/IzomSoftware/GinWrapper/middleware"
+	"github.com/IzomSoftware/GinWrapper/oidc"
+	"github.com/IzomSoftware/GinWrapper/passkeys"
 	"github.com/IzomSoftware/GinWrapper/response"
 	"github.com/IzomSoftware/GinWrapper/server"
+	"github.com/IzomSoftware/GinWrapper/sessions"
 	"github.com/IzomSoftware/GinWrapper/storage"
+	"github.com/IzomSoftware/GinWrapper/tracing"
 	"github.com/gin-gonic/gin"
 )
 
@@ -33,11 +42,40 @@ func main() {
 		panic("Failed to initialize configuration")
 	}
 
-	logLevel := slog.LevelInfo
+	logFile, err := logger.Setup(logger.Options{
+		Output: configuration.Logging.Output,
+		Format: configuration.Logging.Format,
+		File: logger.FileOptions{
+			Path:             configuration.Logging.File.Path,
+			MaxSize:          int64(configuration.Logging.File.MaxSizeMB) * 1024 * 1024,
+			RotationInterval: time.Duration(configuration.Logging.File.RotationIntervalSeconds) * time.Second,
+			MaxAge:           time.Duration(configuration.Logging.File.MaxAgeDays) * 24 * time.Hour,
+			MaxBackups:       configuration.Logging.File.MaxBackups,
+			Compress:         configuration.Logging.File.Compress,
+		},
+		Redaction: redactionOptions(configuration.Logging.Redaction),
+	})
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize logger: %v", err))
+	}
+	if logFile != nil {
+		defer logFile.Close()
+		stopReopen := logFile.ReopenOnSignal(syscall.SIGHUP)
+		defer stopReopen()
+	}
+
+	if err := logger.ApplyLevels(configuration.Logging.Level, configuration.Logging.Levels); err != nil {
+		panic(fmt.Sprintf("Failed to configure log levels: %v", err))
+	}
 	if configuration.Debug {
-		logLevel = slog.LevelDebug
+		logger.SetLevel(slog.LevelDebug)
 	}
-	logger.SetupLogger(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel}))
+
+	shutdownTracing, err := tracing.Setup(context.Background(), configuration.Tracing)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize tracing: %v", err))
+	}
+	defer shutdownTracing(context.Background())
 
 	storage, err := storage.New(configuration, creationSchema)
 	if err != nil {
@@ -45,15 +83,67 @@ func main() {
 	}
 	defer storage.Close()
 
+	var mailSender mailer.Mailer
+	if configuration.Mail.Enabled {
+		if mailSender, err = mailer.New(configuration.Mail); err != nil {
+			panic(fmt.Sprintf("Failed to initialize mailer: %v", err))
+		}
+	}
+
+	server := newServer(configuration, storage, mailSender)
+	if err := server.ListenAndServe(); err != nil {
+		panic(fmt.Sprintf("Failed to listen: %v", err))
+	}
+}
+
+func newServer(configuration *configuration.Config, storage *storage.Storage, mailSender mailer.Mailer) *server.Server {
+	passwordHashing := configuration.Protections.PasswordHashing
+	hasher, err := authentication.NewHasher(passwordHashing.Algorithm, authentication.Argon2Params{
+		Memory:      passwordHashing.Argon2.MemoryKiB,
+		Iterations:  passwordHashing.Argon2.Iterations,
+		Parallelism: passwordHashing.Argon2.Parallelism,
+		SaltLength:  passwordHashing.Argon2.SaltLength,
+		KeyLength:   passwordHashing.Argon2.KeyLength,
+	}, passwordHashing.BcryptCost)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize password hashing: %v", err))
+	}
+	authentication.SetHasher(hasher)
+
+	passwordPolicy, err := newPasswordPolicy(configuration.Protections.PasswordPolicy)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize password policy: %v", err))
+	}
+
+	refreshExpiry := 24 * time.Hour
 	jwtManager := authentication.NewJWTManager(
 		configuration.Protections.JWTProtection.JWTSecret,
 		"GinWrapper",
 		time.Duration(configuration.Protections.JWTProtection.JWTExpiration)*time.Second,
-		24*time.Hour,
+		refreshExpiry,
 	)
 
 	server := server.NewServer(configuration, storage, jwtManager)
-	server.Use(gin.Recovery(), middleware.Logging())
+	server.Use(gin.Recovery(), tracing.Middleware(), middleware.RequestID(), middleware.Logging())
+	if err := server.EnableMetrics(); err != nil {
+		panic(fmt.Sprintf("Failed to initialize metrics: %v", err))
+	}
+
+	if tlsConfiguration := configuration.HTTPServer.TlsConfiguration; tlsConfiguration.HSTS.Enabled {
+		server.Use(middleware.HSTS(tlsConfiguration.HSTS))
+	}
+
+	if securityHeaders := configuration.Protections.SecurityHeaders; securityHeaders.Enabled {
+		server.Use(middleware.SecurityHeaders(securityHeaders))
+	}
+
+	if corsProtection := configuration.Protections.CORSProtection; corsProtection.Enabled {
+		cors, err := middleware.CORS(corsProtection)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to initialize CORS: %v", err))
+		}
+		server.Use(cors)
+	}
 
 	if storage.Redis != nil {
 		server.Use(middleware.BanCheck(storage.Redis))
@@ -62,22 +152,316 @@ func main() {
 		}
 	}
 
+	if securityHeaders := configuration.Protections.SecurityHeaders; securityHeaders.Enabled && securityHeaders.CSPReportPath != "" {
+		server.RegisterRoute("POST", securityHeaders.CSPReportPath, middleware.CSPReport())
+	}
+
+	if csrfProtection := configuration.Protections.CSRFProtection; csrfProtection.Enabled {
+		sessionCookie := ""
+		if configuration.Sessions.Enabled {
+			sessionCookie = configuration.Sessions.CookieName
+		}
+
+		csrf, err := middleware.CSRF(storage.Redis, csrfProtection, configuration.Protections.JWTProtection.JWTSecret, sessionCookie)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to initialize CSRF protection: %v", err))
+		}
+		server.Use(csrf)
+		server.AddTemplateFuncs(middleware.CSRFTemplateFuncs(csrfProtection.FieldName))
+	}
+
+	sessionTracker, err := storage.SessionTracker(refreshExpiry)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize session tracking: %v", err))
+	}
+
+	var sessionManager *sessions.Manager
+	if sessionsConfiguration := configuration.Sessions; sessionsConfiguration.Enabled {
+		sessionManager, err = sessions.NewManager(storage.Redis, sessionsConfiguration, configuration.Protections.JWTProtection.JWTSecret)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to initialize sessions: %v", err))
+		}
+	}
+
+	issueTokens := func(c *gin.Context, username string) (*authentication.JWTPair, error) {
+		device := c.PostForm("device")
+		if device == "" {
+			device = c.Request.UserAgent()
+		}
+
+		session, err := sessionTracker.Create(c.Request.Context(), username, device, c.Request.UserAgent(), c.ClientIP())
+		if err != nil {
+			return nil, err
+		}
+		return jwtManager.GenerateSessionJWTPair(username, username, session.ID)
+	}
+
+	dummyHash, err := authentication.GenerateHash("GinWrapper")
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize password hashing: %v", err))
+	}
+
+	accountLockout := configuration.Protections.AccountLockout
+	lockoutEnabled := accountLockout.Enabled && storage.Redis != nil
+
+	verifyCredentials := func(c *gin.Context, username string, password string) error {
+		if lockoutEnabled {
+			remaining, err := middleware.LoginThrottle(storage.Redis.WithContext(c.Request.Context()), username)
+			if err != nil {
+				return err
+			}
+			if remaining > 0 {
+				return middleware.ErrLoginThrottled
+			}
+		}
+
+		var hash string
+		lookupErr := storage.SQL.QueryRowContext(c.Request.Context(), "SELECT hash FROM Users WHERE username = ?", username).Scan(&hash)
+		if lookupErr != nil {
+			hash = dummyHash
+		}
+
+		_, span := tracing.Start(c.Request.Context(), "authentication.ValidateHash")
+		err := authentication.ValidateHash(hash, password)
+		span.End()
+		if lookupErr != nil {
+			err = lookupErr
+		}
+
+		if lockoutEnabled && err != nil {
+			if recordErr := middleware.RecordLoginFailure(c.Request.Context(), storage.Redis, accountLockout, username, c.ClientIP()); recordErr != nil {
+				logger.WarnContext(c.Request.Context(), "login failure tracking failed", "err", recordErr)
+			}
+		}
+
+		if err != nil || !authentication.NeedsRehash(hash) {
+			return err
+		}
+
+		if hash, err := authentication.GenerateHash(password); err == nil {
+			err = storage.SQL.ExecuteUpdateContext(c.Request.Context(), "UPDATE Users SET hash = ? WHERE username = ?", hash, username)
+			if err != nil {
+				logger.WarnContext(c.Request.Context(), "password rehash failed", "err", err)
+			}
+		}
+		return nil
+	}
+
+	clearLoginFailures := func(c *gin.Context, username string) {
+		if !lockoutEnabled {
+			return
+		}
+		if err := middleware.ResetLoginFailures(storage.Redis.WithContext(c.Request.Context()), username); err != nil {
+			logger.WarnContext(c.Request.Context(), "login failure reset failed", "err", err)
+		}
+	}
+
+	twoFactorConfiguration := configuration.Protections.TwoFactor
+	twoFactor, err := newTwoFactorStore(storage, twoFactorConfiguration)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize two-factor authentication: %v", err))
+	}
+
+	mailConfiguration := configuration.Mail
+	emails, err := newEmailStore(storage, mailConfiguration)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize email storage: %v", err))
+	}
+
+	var mailTemplates *mailer.Templates
+	if mailConfiguration.Enabled {
+		templatesDir := mailConfiguration.TemplatesDir
+		if templatesDir == "" {
+			templatesDir = configuration.HTTPServer.TemplatesDir + "emails/"
+		}
+		mailTemplates = mailer.NewTemplates(templatesDir)
+	}
+
+	sendActionEmail := func(ctx context.Context, username string, email string, binding string, purpose string, template string, path string, ttlSeconds int) error {
+		expiry := time.Duration(ttlSeconds) * time.Second
+		token, err := jwtManager.GenerateActionToken(username, purpose, email, binding, expiry)
+		if err != nil {
+			return err
+		}
+
+		message, err := mailTemplates.Render(template, email, gin.H{
+			"Username":  username,
+			"Link":      mailConfiguration.BaseURL + path + "?token=" + url.QueryEscape(token),
+			"ExpiresIn": expiry.String(),
+		})
+		if err != nil {
+			return err
+		}
+		return mailSender.Send(ctx, message)
+	}
+
+	sendVerificationEmail := func(ctx context.Context, username string, email string) error {
+		return sendActionEmail(ctx, username, email, "", authentication.TokenTypeVerifyEmail, mailer.TemplateVerifyEmail, mailConfiguration.VerifyPath, mailConfiguration.VerifyTokenTTLSeconds)
+	}
+
+	sendPendingVerification := func(ctx context.Context, username string, email string) {
+		ctx = context.WithoutCancel(ctx)
+		go func() {
+			if _, err := emails.UsernameForEmail(ctx, email); err == nil {
+				logger.InfoContext(ctx, "verification email skipped for an address in use", "username", username)
+				return
+			}
+			if err := sendVerificationEmail(ctx, username, email); err != nil {
+				logger.WarnContext(ctx, "verification email failed", "err", err)
+			}
+		}()
+	}
+
+	consumeActionToken := func(c *gin.Context, token string, purpose string) (*authentication.JWTClaims, error) {
+		claims, err := jwtManager.ValidateActionToken(token, purpose)
+		if err != nil {
+			return nil, err
+		}
+		if err := emails.ConsumeToken(c.Request.Context(), claims.ID, claims.ExpiresAt.Time); err != nil {
+			return nil, err
+		}
+		return claims, nil
+	}
+
+	verifyTOTP := func(c *gin.Context, username string, code string) error {
+		enrollment, err := twoFactor.Enrollment(c.Request.Context(), username)
+		if err != nil {
+			return err
+		}
+
+		counter, ok := authentication.ValidateTOTP(enrollment.Secret, code, time.Now(), twoFactorConfiguration.SkewSteps)
+		if !ok {
+			return authentication.ErrInvalidToken
+		}
+		return twoFactor.MarkUsed(c.Request.Context(), username, counter)
+	}
+
+	beginSecondFactor := func(c *gin.Context, username string) bool {
+		if !twoFactorConfiguration.Enabled {
+			return false
+		}
+
+		enabled, err := twoFactor.Enabled(c.Request.Context(), username)
+		if err != nil {
+			response.AbortInternalError(c)
+			return true
+		}
+		if !enabled {
+			return false
+		}
+
+		mfaToken, err := jwtManager.GenerateMFAToken(username, time.Duration(twoFactorConfiguration.MFATokenTTLSeconds)*time.Second)
+		if err != nil {
+			response.AbortInternalError(c)
+			return true
+		}
+
+		c.JSON(http.StatusOK, gin.H{"mfa_required": true, "mfa_token": mfaToken})
+		return true
+	}
+
+	completeSecondFactor := func(c *gin.Context) (string, bool) {
+		if !twoFactorConfiguration.Enabled {
+			response.Abort(c, http.StatusNotFound)
+			return "", false
+		}
+
+		claims, err := jwtManager.ValidateMFAToken(c.PostForm("mfa_token"))
+		if err != nil {
+			response.AbortUnauthorized(c)
+			return "", false
+		}
+		username := claims.Username
+
+		if lockoutEnabled {
+			remaining, err := middleware.LoginThrottle(storage.Redis.WithContext(c.Request.Context()), username)
+			if err != nil || remaining > 0 {
+				response.AbortUnauthorized(c)
+				return "", false
+			}
+		}
+
+		if code := c.PostForm("code"); code != "" {
+			err = verifyTOTP(c, username, code)
+		} else if recoveryCode := c.PostForm("recovery_code"); recoveryCode != "" {
+			var consumed bool
+			if consumed, err = twoFactor.ConsumeRecoveryCode(c.Request.Context(), username, recoveryCode); err == nil && !consumed {
+				err = authentication.ErrInvalidToken
+			}
+			if consumed {
+				logger.InfoContext(c.Request.Context(), "recovery code used", "username", username)
+			}
+		} else {
+			err = authentication.ErrInvalidToken
+		}
+
+		if err != nil {
+			if lockoutEnabled {
+				if recordErr := middleware.RecordLoginFailure(c.Request.Context(), storage.Redis, accountLockout, username, c.ClientIP()); recordErr != nil {
+					logger.WarnContext(c.Request.Context(), "login failure tracking failed", "err", recordErr)
+				}
+			}
+			response.AbortUnauthorized(c)
+			return "", false
+		}
+
+		clearLoginFailures(c, username)
+		return username, true
+	}
+
 	server.RegisterRoute("POST", "/api/auth/register", func(c *gin.Context) {
 		username, password := c.PostForm("username"), c.PostForm("password")
+		if passwordPolicy != nil {
+			var validationErrors authentication.ValidationErrors
+			if err := passwordPolicy.Validate(username, password); errors.As(err, &validationErrors) {
+				response.AbortWithErrors(c, http.StatusUnprocessableEntity, validationErrors)
+				return
+			} else if err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+		}
+
+		email := c.PostForm("email")
+		if email != "" {
+			if !mailConfiguration.Enabled {
+				response.Abort(c, http.StatusBadRequest)
+				return
+			}
+			normalized, err := authentication.NormalizeEmail(email)
+			if err != nil {
+				response.AbortWithErrors(c, http.StatusUnprocessableEntity, authentication.ValidationErrors{
+					{Field: "email", Code: "invalid", Message: "email address is invalid"},
+				})
+				return
+			}
+			email = normalized
+		}
+
+		_, span := tracing.Start(c.Request.Context(), "authentication.GenerateHash")
 		hash, err := authentication.GenerateHash(password)
+		span.End()
 
 		if err != nil {
 			response.AbortInternalError(c)
 			return
 		}
 
-		err = storage.SQL.ExecuteUpdate("INSERT INTO Users (username, hash) VALUES (?, ?)", username, hash)
+		err = storage.SQL.ExecuteUpdateContext(c.Request.Context(), "INSERT INTO Users (username, hash) VALUES (?, ?)", username, hash)
 		if err != nil {
 			response.Abort(c, http.StatusBadRequest)
 			return
 		}
 
-		pair, err := jwtManager.GenerateJWTPair(username, username)
+		if email != "" {
+			if err := emails.SetPending(c.Request.Context(), username, email); err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+			sendPendingVerification(c.Request.Context(), username, email)
+		}
+
+		pair, err := issueTokens(c, username)
 		if err != nil {
 			response.AbortInternalError(c)
 			return
@@ -86,16 +470,153 @@ func main() {
 		c.JSON(http.StatusOK, pair)
 	})
 
+	if mailConfiguration.Enabled {
+		verifyEmail := func(c *gin.Context) {
+			token := c.Query("token")
+			if token == "" {
+				token = c.PostForm("token")
+			}
+
+			claims, err := consumeActionToken(c, token, authentication.TokenTypeVerifyEmail)
+			if err != nil {
+				response.Abort(c, http.StatusBadRequest)
+				return
+			}
+
+			err = emails.MarkVerified(c.Request.Context(), claims.Username, claims.Email)
+			if errors.Is(err, authentication.ErrEmailNotFound) {
+				response.Abort(c, http.StatusBadRequest)
+				return
+			} else if errors.Is(err, authentication.ErrEmailTaken) {
+				response.Abort(c, http.StatusConflict)
+				return
+			} else if err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"username": claims.Username, "email": claims.Email, "verified": true})
+		}
+		server.RegisterRoute("GET", "/api/auth/email/verify", verifyEmail)
+		server.RegisterRoute("POST", "/api/auth/email/verify", verifyEmail)
+
+		server.RegisterRoute("POST", "/api/auth/password/reset-request", func(c *gin.Context) {
+			email, _ := authentication.NormalizeEmail(c.PostForm("email"))
+			username, err := emails.UsernameForEmail(c.Request.Context(), email)
+			var hash string
+			if err == nil {
+				err = storage.SQL.QueryRowContext(c.Request.Context(), "SELECT hash FROM Users WHERE username = ?", username).Scan(&hash)
+			}
+			if err == nil {
+				ctx := context.WithoutCancel(c.Request.Context())
+				go func() {
+					err := sendActionEmail(ctx, username, email, authentication.HashFingerprint(hash), authentication.TokenTypePasswordReset, mailer.TemplatePasswordReset, mailConfiguration.ResetPath, mailConfiguration.ResetTokenTTLSeconds)
+					if err != nil {
+						logger.WarnContext(ctx, "password reset email failed", "err", err)
+					}
+				}()
+			} else if !errors.Is(err, authentication.ErrEmailNotFound) {
+				logger.WarnContext(c.Request.Context(), "password reset lookup failed", "err", err)
+			}
+
+			c.Status(http.StatusAccepted)
+		})
+
+		server.RegisterRoute("POST", "/api/auth/password/reset", func(c *gin.Context) {
+			token, password := c.PostForm("token"), c.PostForm("password")
+			claims, err := jwtManager.ValidateActionToken(token, authentication.TokenTypePasswordReset)
+			if err != nil {
+				response.Abort(c, http.StatusBadRequest)
+				return
+			}
+
+			var currentHash string
+			err = storage.SQL.QueryRowContext(c.Request.Context(), "SELECT hash FROM Users WHERE username = ?", claims.Username).Scan(&currentHash)
+			if err != nil || !authentication.MatchesFingerprint(currentHash, claims.Binding) {
+				response.Abort(c, http.StatusBadRequest)
+				return
+			}
+
+			if passwordPolicy != nil {
+				var validationErrors authentication.ValidationErrors
+				if err := passwordPolicy.ValidatePassword(claims.Username, password); errors.As(err, &validationErrors) {
+					response.AbortWithErrors(c, http.StatusUnprocessableEntity, validationErrors)
+					return
+				} else if err != nil {
+					response.AbortInternalError(c)
+					return
+				}
+			}
+
+			if _, err := consumeActionToken(c, token, authentication.TokenTypePasswordReset); err != nil {
+				response.Abort(c, http.StatusBadRequest)
+				return
+			}
+
+			hash, err := authentication.GenerateHash(password)
+			if err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+
+			affected, err := storage.SQL.ExecuteAffectedContext(c.Request.Context(),
+				"UPDATE Users SET hash = ? WHERE username = ? AND hash = ?", hash, claims.Username, currentHash,
+			)
+			if err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+			if affected == 0 {
+				response.Abort(c, http.StatusBadRequest)
+				return
+			}
+
+			if err := sessionTracker.RevokeAll(c.Request.Context(), claims.Username, ""); err != nil {
+				logger.WarnContext(c.Request.Context(), "session revocation after password reset failed", "err", err)
+			}
+			if sessionManager != nil {
+				if err := sessionManager.LogoutEverywhere(c.Request.Context(), claims.Username); err != nil {
+					logger.WarnContext(c.Request.Context(), "cookie session revocation after password reset failed", "err", err)
+				}
+			}
+			if lockoutEnabled {
+				if err := middleware.UnlockAccount(storage.Redis, claims.Username); err != nil {
+					logger.WarnContext(c.Request.Context(), "unlock after password reset failed", "err", err)
+				}
+			}
+
+			c.Status(http.StatusNoContent)
+		})
+	}
+
 	server.RegisterRoute("POST", "/api/auth/login", func(c *gin.Context) {
 		username, password := c.PostForm("username"), c.PostForm("password")
-		var hash string
-		err := storage.SQL.QueryRow("SELECT hash FROM Users WHERE username = ?", username).Scan(&hash)
-		if err != nil || authentication.ValidateHash(hash, password) != nil {
+		if err := verifyCredentials(c, username, password); err != nil {
 			response.AbortUnauthorized(c)
 			return
 		}
 
-		pair, err := jwtManager.GenerateJWTPair(username, username)
+		if beginSecondFactor(c, username) {
+			return
+		}
+		clearLoginFailures(c, username)
+
+		pair, err := issueTokens(c, username)
+		if err != nil {
+			response.AbortInternalError(c)
+			return
+		}
+
+		c.JSON(http.StatusOK, pair)
+	})
+
+	server.RegisterRoute("POST", "/api/auth/login/mfa", func(c *gin.Context) {
+		username, ok := completeSecondFactor(c)
+		if !ok {
+			return
+		}
+
+		pair, err := issueTokens(c, username)
 		if err != nil {
 			response.AbortInternalError(c)
 			return
@@ -106,10 +627,20 @@ func main() {
 
 	server.RegisterRoute("POST", "/api/auth/refresh", func(c *gin.Context) {
 		refreshToken := c.PostForm("refresh_token")
-		pair, err := jwtManager.RefreshToken(refreshToken)
+		claims, err := jwtManager.ValidateRefreshJWT(refreshToken)
+		if err != nil || sessionTracker.Validate(c.Request.Context(), claims.SessionID, claims.Username) != nil {
+			response.AbortUnauthorized(c)
+			return
+		}
+
+		if err := sessionTracker.Touch(c.Request.Context(), claims.SessionID, c.ClientIP()); err != nil {
+			response.AbortInternalError(c)
+			return
+		}
 
+		pair, err := jwtManager.GenerateSessionJWTPair(claims.Uuid, claims.Username, claims.SessionID)
 		if err != nil {
-			response.AbortUnauthorized(c)
+			response.AbortInternalError(c)
 			return
 		}
 
@@ -118,12 +649,589 @@ func main() {
 
 	protected := server.Engine.Group("/api/protected")
 	protected.Use(middleware.UserAgent(configuration.Protections.APIUserAgent))
-	protected.Use(middleware.Authentication(jwtManager))
+	if tlsConfiguration := configuration.HTTPServer.TlsConfiguration; tlsConfiguration.ClientCAFile != "" {
+		protected.Use(middleware.ClientCertificate(tlsConfiguration.ClientIdentitySource))
+	}
+	protected.Use(middleware.Authentication(jwtManager, sessionTracker))
+	protected.GET("/me", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"uuid":        c.GetString("uuid"),
+			"username":    c.GetString("username"),
+			"auth_method": c.GetString("auth_method"),
+		})
+	})
+	protected.GET("/sessions", func(c *gin.Context) {
+		sessions, err := sessionTracker.List(c.Request.Context(), c.GetString("username"))
+		if err != nil {
+			response.AbortInternalError(c)
+			return
+		}
+
+		for i := range sessions {
+			sessions[i].Current = sessions[i].ID == c.GetString("session_id")
+		}
+		c.JSON(http.StatusOK, sessions)
+	})
+	protected.DELETE("/sessions/:id", func(c *gin.Context) {
+		err := sessionTracker.Revoke(c.Request.Context(), c.GetString("username"), c.Param("id"))
+		if errors.Is(err, authentication.ErrSessionNotFound) {
+			response.Abort(c, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			response.AbortInternalError(c)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	})
+	protected.DELETE("/sessions", func(c *gin.Context) {
+		except := ""
+		if c.Query("keep_current") == "true" {
+			except = c.GetString("session_id")
+		}
+
+		if err := sessionTracker.RevokeAll(c.Request.Context(), c.GetString("username"), except); err != nil {
+			response.AbortInternalError(c)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	})
+
+	if mailConfiguration.Enabled {
+		protected.GET("/email", func(c *gin.Context) {
+			email, err := emails.Email(c.Request.Context(), c.GetString("username"))
+			if errors.Is(err, authentication.ErrEmailNotFound) {
+				response.Abort(c, http.StatusNotFound)
+				return
+			} else if err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+
+			c.JSON(http.StatusOK, email)
+		})
+		protected.PUT("/email", func(c *gin.Context) {
+			username := c.GetString("username")
+			email, err := authentication.NormalizeEmail(c.PostForm("email"))
+			if err != nil {
+				response.AbortWithErrors(c, http.StatusUnprocessableEntity, authentication.ValidationErrors{
+					{Field: "email", Code: "invalid", Message: "email address is invalid"},
+				})
+				return
+			}
+
+			if err := verifyCredentials(c, username, c.PostForm("password")); err != nil {
+				response.AbortUnauthorized(c)
+				return
+			}
+
+			current, err := emails.Email(c.Request.Context(), username)
+			if err != nil && !errors.Is(err, authentication.ErrEmailNotFound) {
+				response.AbortInternalError(c)
+				return
+			}
+			if err := emails.SetPending(c.Request.Context(), username, email); err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+			if current == nil || current.Email != email {
+				sendPendingVerification(c.Request.Context(), username, email)
+			}
+
+			c.Status(http.StatusAccepted)
+		})
+		protected.POST("/email/send-verification", func(c *gin.Context) {
+			username := c.GetString("username")
+			email, err := emails.Email(c.Request.Context(), username)
+			if errors.Is(err, authentication.ErrEmailNotFound) {
+				response.Abort(c, http.StatusNotFound)
+				return
+			} else if err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+			if email.Pending == "" {
+				response.Abort(c, http.StatusConflict)
+				return
+			}
+
+			sendPendingVerification(c.Request.Context(), username, email.Pending)
+			c.Status(http.StatusAccepted)
+		})
+	}
+
+	if twoFactorConfiguration.Enabled {
+		mfa := protected.Group("/mfa")
+		mfa.POST("/totp/enroll", func(c *gin.Context) {
+			username := c.GetString("username")
+			enabled, err := twoFactor.Enabled(c.Request.Context(), username)
+			if err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+			if enabled {
+				response.Abort(c, http.StatusConflict)
+				return
+			}
+
+			secret, err := authentication.GenerateTOTPSecret()
+			if err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+			if err := twoFactor.BeginEnrollment(c.Request.Context(), username, secret); err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"secret": secret,
+				"uri":    authentication.TOTPURI(twoFactorConfiguration.Issuer, username, secret),
+			})
+		})
+		mfa.GET("/totp/qr", func(c *gin.Context) {
+			username := c.GetString("username")
+			enrollment, err := twoFactor.Enrollment(c.Request.Context(), username)
+			if errors.Is(err, authentication.ErrTOTPNotEnrolled) {
+				response.Abort(c, http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+			if enrollment.Enabled {
+				response.Abort(c, http.StatusConflict)
+				return
+			}
+
+			png, err := authentication.TOTPQRCode(authentication.TOTPURI(twoFactorConfiguration.Issuer, username, enrollment.Secret), twoFactorConfiguration.QRCodeSize)
+			if err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+
+			c.Header("Cache-Control", "no-store")
+			c.Data(http.StatusOK, "image/png", png)
+		})
+		mfa.POST("/totp/confirm", func(c *gin.Context) {
+			username := c.GetString("username")
+			enrollment, err := twoFactor.Enrollment(c.Request.Context(), username)
+			if errors.Is(err, authentication.ErrTOTPNotEnrolled) {
+				response.Abort(c, http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+			if enrollment.Enabled {
+				response.Abort(c, http.StatusConflict)
+				return
+			}
+
+			counter, ok := authentication.ValidateTOTP(enrollment.Secret, c.PostForm("code"), time.Now(), twoFactorConfiguration.SkewSteps)
+			if !ok {
+				response.AbortUnauthorized(c)
+				return
+			}
+
+			codes, err := authentication.GenerateRecoveryCodes(twoFactorConfiguration.RecoveryCodes)
+			if err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+			if err := twoFactor.ReplaceRecoveryCodes(c.Request.Context(), username, codes); err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+			if err := twoFactor.ConfirmEnrollment(c.Request.Context(), username, counter); err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+
+			logger.InfoContext(c.Request.Context(), "totp enabled", "username", username)
+			c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+		})
+		mfa.POST("/recovery-codes", func(c *gin.Context) {
+			username := c.GetString("username")
+			if err := verifyTOTP(c, username, c.PostForm("code")); err != nil {
+				response.AbortUnauthorized(c)
+				return
+			}
+
+			codes, err := authentication.GenerateRecoveryCodes(twoFactorConfiguration.RecoveryCodes)
+			if err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+			if err := twoFactor.ReplaceRecoveryCodes(c.Request.Context(), username, codes); err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+		})
+		mfa.DELETE("/totp", func(c *gin.Context) {
+			username := c.GetString("username")
+			if err := verifyTOTP(c, username, c.PostForm("code")); err != nil {
+				response.AbortUnauthorized(c)
+				return
+			}
+
+			if err := twoFactor.Disable(c.Request.Context(), username); err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+
+			logger.InfoContext(c.Request.Context(), "totp disabled", "username", username)
+			c.Status(http.StatusNoContent)
+		})
+	}
+
+	if passkeysConfiguration := configuration.Protections.Passkeys; passkeysConfiguration.Enabled {
+		passkeyManager, err := passkeys.NewManager(storage, passkeysConfiguration)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to initialize passkeys: %v", err))
+		}
+
+		server.RegisterRoute("POST", "/api/auth/passkey/begin", func(c *gin.Context) {
+			assertion, challengeID, err := passkeyManager.BeginLogin(c.Request.Context(), c.PostForm("username"))
+			if err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"challenge_id": challengeID, "options": assertion})
+		})
+		server.RegisterRoute("POST", "/api/auth/passkey/finish", func(c *gin.Context) {
+			username, err := passkeyManager.FinishLogin(c.Request.Context(), c.Query("challenge_id"), c.Request)
+			if err != nil {
+				logger.DebugContext(c.Request.Context(), "passkey login failed", "err", err)
+				response.AbortUnauthorized(c)
+				return
+			}
+
+			if lockoutEnabled {
+				remaining, err := middleware.LoginThrottle(storage.Redis.WithContext(c.Request.Context()), username)
+				if err != nil || remaining > 0 {
+					response.AbortUnauthorized(c)
+					return
+				}
+			}
+			if !passkeyManager.VerifiesUser() && beginSecondFactor(c, username) {
+				return
+			}
+
+			pair, err := issueTokens(c, username)
+			if err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+
+			c.JSON(http.StatusOK, pair)
+		})
+
+		protected.GET("/passkeys", func(c *gin.Context) {
+			registered, err := passkeyManager.List(c.Request.Context(), c.GetString("username"))
+			if err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+
+			c.JSON(http.StatusOK, registered)
+		})
+		protected.POST("/passkeys/register/begin", func(c *gin.Context) {
+			creation, challengeID, err := passkeyManager.BeginRegistration(c.Request.Context(), c.GetString("username"))
+			if err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"challenge_id": challengeID, "options": creation})
+		})
+		protected.POST("/passkeys/register/finish", func(c *gin.Context) {
+			err := passkeyManager.FinishRegistration(c.Request.Context(), c.GetString("username"), c.Query("challenge_id"), c.DefaultQuery("name", "Passkey"), c.Request)
+			if err != nil {
+				logger.DebugContext(c.Request.Context(), "passkey registration failed", "err", err)
+				response.Abort(c, http.StatusBadRequest)
+				return
+			}
+
+			c.Status(http.StatusCreated)
+		})
+		protected.DELETE("/passkeys/:id", func(c *gin.Context) {
+			err := passkeyManager.Remove(c.Request.Context(), c.GetString("username"), c.Param("id"))
+			if errors.Is(err, passkeys.ErrPasskeyNotFound) {
+				response.Abort(c, http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+
+			c.Status(http.StatusNoContent)
+		})
+	}
+
+	if oidcConfiguration := configuration.Protections.OIDC; oidcConfiguration.Enabled {
+		oidcManager, err := oidc.NewManager(storage, oidcConfiguration, passwordPolicy)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to initialize OIDC: %v", err))
+		}
+
+		setStateCookie := func(c *gin.Context, value string, maxAge int) {
+			http.SetCookie(c.Writer, &http.Cookie{
+				Name:     oidcConfiguration.CookieName,
+				Value:    value,
+				Path:     "/",
+				MaxAge:   maxAge,
+				Secure:   oidcConfiguration.SecureCookie,
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+
+		server.RegisterRoute("GET", "/api/auth/oidc/providers", func(c *gin.Context) {
+			c.JSON(http.StatusOK, oidcManager.Providers())
+		})
+		server.RegisterRoute("GET", "/api/auth/oidc/:provider/login", func(c *gin.Context) {
+			authorizationURL, stateID, err := oidcManager.Begin(c.Request.Context(), c.Param("provider"), "")
+			if errors.Is(err, oidc.ErrUnknownProvider) {
+				response.Abort(c, http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				logger.WarnContext(c.Request.Context(), "oidc login could not start", "provider", c.Param("provider"), "err", err)
+				response.Abort(c, http.StatusBadGateway)
+				return
+			}
+
+			setStateCookie(c, stateID, int(oidcManager.StateTTL().Seconds()))
+			c.Redirect(http.StatusFound, authorizationURL)
+		})
+		server.RegisterRoute("GET", "/api/auth/oidc/:provider/callback", func(c *gin.Context) {
+			if providerError := c.Query("error"); providerError != "" {
+				logger.DebugContext(c.Request.Context(), "oidc provider returned an error", "provider", c.Param("provider"), "error", providerError)
+				response.AbortUnauthorized(c)
+				return
+			}
+
+			browserState, _ := c.Cookie(oidcConfiguration.CookieName)
+			setStateCookie(c, "", -1)
+
+			result, err := oidcManager.Finish(c.Request.Context(), c.Param("provider"), c.Query("state"), browserState, c.Query("code"))
+			var validationErrors authentication.ValidationErrors
+			switch {
+			case errors.Is(err, oidc.ErrUnknownProvider):
+				response.Abort(c, http.StatusNotFound)
+				return
+			case errors.Is(err, oidc.ErrIdentityLinked), errors.Is(err, oidc.ErrUsernameTaken):
+				response.Abort(c, http.StatusConflict)
+				return
+			case errors.As(err, &validationErrors):
+				response.AbortWithErrors(c, http.StatusUnprocessableEntity, validationErrors)
+				return
+			case err != nil:
+				logger.DebugContext(c.Request.Context(), "oidc login failed", "provider", c.Param("provider"), "err", err)
+				response.AbortUnauthorized(c)
+				return
+			}
+
+			if result.Linking {
+				c.JSON(http.StatusOK, gin.H{"provider": result.Provider, "linked": true})
+				return
+			}
+			if beginSecondFactor(c, result.Username) {
+				return
+			}
+
+			pair, err := issueTokens(c, result.Username)
+			if err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+
+			c.JSON(http.StatusOK, pair)
+		})
+
+		protected.GET("/oidc/identities", func(c *gin.Context) {
+			identities, err := oidcManager.Identities(c.Request.Context(), c.GetString("username"))
+			if err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+
+			c.JSON(http.StatusOK, identities)
+		})
+		protected.POST("/oidc/:provider/link", func(c *gin.Context) {
+			authorizationURL, stateID, err := oidcManager.Begin(c.Request.Context(), c.Param("provider"), c.GetString("username"))
+			if errors.Is(err, oidc.ErrUnknownProvider) {
+				response.Abort(c, http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				logger.WarnContext(c.Request.Context(), "oidc link could not start", "provider", c.Param("provider"), "err", err)
+				response.Abort(c, http.StatusBadGateway)
+				return
+			}
+
+			setStateCookie(c, stateID, int(oidcManager.StateTTL().Seconds()))
+			c.JSON(http.StatusOK, gin.H{"authorization_url": authorizationURL})
+		})
+		protected.DELETE("/oidc/identities/:provider", func(c *gin.Context) {
+			err := oidcManager.Unlink(c.Request.Context(), c.GetString("username"), c.Param("provider"))
+			switch {
+			case errors.Is(err, oidc.ErrIdentityNotFound):
+				response.Abort(c, http.StatusNotFound)
+				return
+			case errors.Is(err, oidc.ErrLastSignInMethod):
+				response.Abort(c, http.StatusConflict)
+				return
+			case err != nil:
+				response.AbortInternalError(c)
+				return
+			}
+
+			c.Status(http.StatusNoContent)
+		})
+	}
+
+	if sessionManager != nil {
+		session := server.Engine.Group("/session")
+		session.Use(sessionManager.Middleware())
+		session.POST("/login", func(c *gin.Context) {
+			username, password := c.PostForm("username"), c.PostForm("password")
+			if err := verifyCredentials(c, username, password); err != nil {
+				response.AbortUnauthorized(c)
+				return
+			}
+
+			if beginSecondFactor(c, username) {
+				return
+			}
+			clearLoginFailures(c, username)
+
+			if err := sessions.Get(c).Login(username); err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"username": username})
+		})
+		session.POST("/login/mfa", func(c *gin.Context) {
+			username, ok := completeSecondFactor(c)
+			if !ok {
+				return
+			}
+
+			if err := sessions.Get(c).Login(username); err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"username": username})
+		})
+		session.POST("/logout", func(c *gin.Context) {
+			if err := sessions.Get(c).Destroy(); err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+
+			c.Status(http.StatusNoContent)
+		})
+
+		authenticated := session.Group("", sessionManager.RequireUser())
+		authenticated.GET("/me", func(c *gin.Context) {
+			flashes, err := sessions.Get(c).Flashes()
+			if err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"username":    c.GetString("username"),
+				"auth_method": c.GetString("auth_method"),
+				"flashes":     flashes,
+			})
+		})
+		authenticated.POST("/logout-all", func(c *gin.Context) {
+			if err := sessionManager.LogoutEverywhere(c.Request.Context(), c.GetString("username")); err != nil {
+				response.AbortInternalError(c)
+				return
+			}
+
+			c.Status(http.StatusNoContent)
+		})
+	}
 
 	server.LoadTemplates(configuration.HTTPServer.TemplatesDir + "*")
 	server.LoadStatics(configuration.HTTPServer.AssetsDir, "."+configuration.HTTPServer.AssetsDir)
 
-	if err := server.ListenAndServe(); err != nil {
-		panic(fmt.Sprintf("Failed to listen: %v", err))
+	return server
+}
+
+func redactionOptions(redaction configuration.LogRedaction) *logger.RedactionOptions {
+	if !redaction.Enabled {
+		return nil
+	}
+	return &logger.RedactionOptions{
+		Keys:    redaction.Keys,
+		Values:  redaction.Values,
+		MaskIPs: redaction.MaskIPs,
+	}
+}
+
+func newTwoFactorStore(store *storage.Storage, twoFactor configuration.TwoFactor) (*storage.TwoFactorStore, error) {
+	if !twoFactor.Enabled {
+		return nil, nil
+	}
+	return store.TwoFactor()
+}
+
+func newEmailStore(store *storage.Storage, mail configuration.Mail) (*storage.EmailStore, error) {
+	if !mail.Enabled {
+		return nil, nil
+	}
+	return store.Emails()
+}
+
+func newPasswordPolicy(policy configuration.PasswordPolicy) (*authentication.PasswordPolicy, error) {
+	if !policy.Enabled {
+		return nil, nil
+	}
+
+	passwordPolicy := &authentication.PasswordPolicy{
+		MinLength:         policy.MinLength,
+		MaxLength:         policy.MaxLength,
+		RequireUppercase:  policy.RequireUppercase,
+		RequireLowercase:  policy.RequireLowercase,
+		RequireDigit:      policy.RequireDigit,
+		RequireSymbol:     policy.RequireSymbol,
+		MinStrengthScore:  policy.MinStrengthScore,
+		ForbidUsername:    policy.ForbidUsername,
+		MinUsernameLength: policy.MinUsernameLength,
+		MaxUsernameLength: policy.MaxUsernameLength,
 	}
+
+	if policy.UsernamePattern != "" {
+		pattern, err := regexp.Compile(policy.UsernamePattern)
+		if err != nil {
+			return nil, err
+		}
+		passwordPolicy.UsernamePattern = pattern
+	}
+
+	if policy.BreachedPasswordsDir != "" {
+		breached, err := authentication.NewBreachedPasswords(policy.BreachedPasswordsDir)
+		if err != nil {
+			return nil, err
+		}
+		passwordPolicy.Breached = breached
+	}
+
+	return passwordPolicy, nil
 }
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IzomSoftware/GinWrapper/authentication"
+	"github.com/IzomSoftware/GinWrapper/configuration"
+	"github.com/IzomSoftware/GinWrapper/mailer"
+	"github.com/IzomSoftware/GinWrapper/server"
+	"github.com/IzomSoftware/GinWrapper/storage/storagetest"
+	"github.com/gin-gonic/gin"
+)
+
+const testPassword = "correct horse battery staple"
+
+func newTestServer(t *testing.T, configure func(config *configuration.Config)) (*server.Server, *mailer.MemoryMailer) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	config := configuration.Default
+	storagetest.Configure(t, &config)
+
+	templates := t.TempDir()
+	if err := os.WriteFile(filepath.Join(templates, "index.html"), []byte("GinWrapper"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config.HTTPServer.TemplatesDir = templates + "/"
+	config.Protections.JWTProtection.JWTSecret = "test-secret"
+	config.Protections.RateLimitProtection.Enabled = false
+	config.Protections.PasswordHashing.Algorithm = "bcrypt"
+	config.Protections.PasswordHashing.BcryptCost = 4
+	config.Protections.PasswordPolicy.BreachedPasswordsDir = ""
+	config.Metrics.Enabled = false
+	if configure != nil {
+		configure(&config)
+	}
+
+	mailbox := mailer.NewMemoryMailer()
+	return newServer(&config, storagetest.Open(t, &config, creationSchema), mailbox), mailbox
+}
+
+func request(t *testing.T, server *server.Server, method string, path string, form url.Values, bearer string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	t.Helper()
+	request := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if bearer != "" {
+		request.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+
+	recorder := httptest.NewRecorder()
+	server.Engine.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func decode(t *testing.T, recorder *httptest.ResponseRecorder, target any) {
+	t.Helper()
+	if err := json.Unmarshal(recorder.Body.Bytes(), target); err != nil {
+		t.Fatalf("decode %q: %v", recorder.Body.String(), err)
+	}
+}
+
+func register(t *testing.T, server *server.Server, username string) authentication.JWTPair {
+	t.Helper()
+	return registerWithEmail(t, server, username, "")
+}
+
+func registerWithEmail(t *testing.T, server *server.Server, username string, email string) authentication.JWTPair {
+	t.Helper()
+	form := url.Values{"username": {username}, "password": {testPassword}}
+	if email != "" {
+		form.Set("email", email)
+	}
+	recorder := request(t, server, http.MethodPost, "/api/auth/register", form, "")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("register = %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	var pair authentication.JWTPair
+	decode(t, recorder, &pair)
+	return pair
+}
+
+func enrollTOTP(t *testing.T, server *server.Server, accessJWT string) []string {
+	t.Helper()
+	recorder := request(t, server, http.MethodPost, "/api/protected/mfa/totp/enroll", nil, accessJWT)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("enroll = %d %s", recorder.Code, recorder.Body.String())
+	}
+	var enrollment struct {
+		Secret string `json:"secret"`
+	}
+	decode(t, recorder, &enrollment)
+
+	code, err := authentication.TOTPCode(enrollment.Secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder = request(t, server, http.MethodPost, "/api/protected/mfa/totp/confirm", url.Values{"code": {code}}, accessJWT)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("confirm = %d %s", recorder.Code, recorder.Body.String())
+	}
+	var confirmation struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	decode(t, recorder, &confirmation)
+	return confirmation.RecoveryCodes
+}
+
+func passwordLogin(t *testing.T, server *server.Server, username string) string {
+	t.Helper()
+	recorder := request(t, server, http.MethodPost, "/api/auth/login", url.Values{"username": {username}, "password": {testPassword}}, "")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("login = %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	var challenge struct {
+		MFAToken string `json:"mfa_token"`
+	}
+	decode(t, recorder, &challenge)
+	if challenge.MFAToken == "" {
+		t.Fatalf("login did not ask for a second factor: %s", recorder.Body.String())
+	}
+	return challenge.MFAToken
+}
+
+func secondFactor(t *testing.T, server *server.Server, mfaToken string, field string, code string) int {
+	t.Helper()
+	return request(t, server, http.MethodPost, "/api/auth/login/mfa", url.Values{"mfa_token": {mfaToken}, field: {code}}, "").Code
+}
+
+func withLockout(config *configuration.Config) {
+	config.Protections.AccountLockout = configuration.AccountLockout{
+		Enabled:                true,
+		FailureWindowSeconds:   900,
+		DelayAfter:             100,
+		LockoutThreshold:       3,
+		LockoutDurationSeconds: 900,
+	}
+}
+
+func TestPasswordLoginKeepsSecondFactorFailures(t *testing.T) {
+	server, _ := newTestServer(t, withLockout)
+	recoveryCodes := enrollTOTP(t, server, register(t, server, "alice").AccessJWT)
+
+	mfaToken := passwordLogin(t, server, "alice")
+	for range 2 {
+		if code := secondFactor(t, server, mfaToken, "code", "invalid"); code != http.StatusUnauthorized {
+			t.Fatalf("wrong code = %d, want 401", code)
+		}
+	}
+
+	mfaToken = passwordLogin(t, server, "alice")
+	if code := secondFactor(t, server, mfaToken, "code", "invalid"); code != http.StatusUnauthorized {
+		t.Fatalf("wrong code = %d, want 401", code)
+	}
+
+	if code := secondFactor(t, server, mfaToken, "recovery_code", recoveryCodes[0]); code != http.StatusUnauthorized {
+		t.Fatalf("recovery code on a locked account = %d, want 401", code)
+	}
+	recorder := request(t, server, http.MethodPost, "/api/auth/login", url.Values{"username": {"alice"}, "password": {testPassword}}, "")
+	if recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("password login on a locked account = %d, want 401", recorder.Code)
+	}
+}
+
+func TestSecondFactorClearsFailures(t *testing.T) {
+	server, _ := newTestServer(t, withLockout)
+	recoveryCodes := enrollTOTP(t, server, register(t, server, "alice").AccessJWT)
+
+	mfaToken := passwordLogin(t, server, "alice")
+	for range 2 {
+		secondFactor(t, server, mfaToken, "code", "invalid")
+	}
+	if code := secondFactor(t, server, mfaToken, "recovery_code", recoveryCodes[0]); code != http.StatusOK {
+		t.Fatalf("recovery code = %d, want 200", code)
+	}
+	if code := secondFactor(t, server, mfaToken, "recovery_code", recoveryCodes[0]); code != http.StatusUnauthorized {
+		t.Fatalf("reused recovery code = %d, want 401", code)
+	}
+
+	mfaToken = passwordLogin(t, server, "alice")
+	if code := secondFactor(t, server, mfaToken, "recovery_code", recoveryCodes[1]); code != http.StatusOK {
+		t.Fatalf("recovery code after a successful login = %d, want 200", code)
+	}
+}
+
+func withMail(config *configuration.Config) {
+	config.Mail.Enabled = true
+	config.Mail.TemplatesDir = ""
+}
+
+var tokenPattern = regexp.MustCompile(`token=([^\s&"<]+)`)
+
+func waitForToken(t *testing.T, mailbox *mailer.MemoryMailer, count int) string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if messages := mailbox.Messages(); len(messages) >= count {
+			match := tokenPattern.FindStringSubmatch(messages[count-1].TextBody)
+			if match == nil {
+				t.Fatalf("message %d carries no token: %q", count, messages[count-1].TextBody)
+			}
+			token, err := url.QueryUnescape(match[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			return token
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for message %d", count)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func verifyEmail(t *testing.T, server *server.Server, token string) int {
+	t.Helper()
+	return request(t, server, http.MethodGet, "/api/auth/email/verify?token="+url.QueryEscape(token), nil, "").Code
+}
+
+func resetPassword(t *testing.T, server *server.Server, token string, password string) int {
+	t.Helper()
+	return request(t, server, http.MethodPost, "/api/auth/password/reset", url.Values{"token": {token}, "password": {password}}, "").Code
+}
+
+func TestEmailVerificationTokenIsSingleUse(t *testing.T) {
+	server, mailbox := newTestServer(t, withMail)
+	pair := registerWithEmail(t, server, "alice", "Alice@Example.com")
+
+	token := waitForToken(t, mailbox, 1)
+	if code := verifyEmail(t, server, token); code != http.StatusOK {
+		t.Fatalf("verify = %d, want 200", code)
+	}
+	if code := verifyEmail(t, server, token); code != http.StatusBadRequest {
+		t.Fatalf("second verify = %d, want 400", code)
+	}
+
+	recorder := request(t, server, http.MethodGet, "/api/protected/email", nil, pair.AccessJWT)
+	var email struct {
+		Email    string `json:"email"`
+		Verified bool   `json:"verified"`
+	}
+	decode(t, recorder, &email)
+	if email.Email != "alice@example.com" || !email.Verified {
+		t.Fatalf("email = %+v, want a verified alice@example.com", email)
+	}
+}
+
+func TestEmailTokensExpire(t *testing.T) {
+	server, mailbox := newTestServer(t, func(config *configuration.Config) {
+		withMail(config)
+		config.Mail.VerifyTokenTTLSeconds = 1
+	})
+	registerWithEmail(t, server, "alice", "alice@example.com")
+
+	token := waitForToken(t, mailbox, 1)
+	time.Sleep(2 * time.Second)
+	if code := verifyEmail(t, server, token); code != http.StatusBadRequest {
+		t.Fatalf("verify with an expired token = %d, want 400", code)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	server, mailbox := newTestServer(t, func(config *configuration.Config) {
+		withMail(config)
+		config.Sessions.Enabled = true
+	})
+	pair := registerWithEmail(t, server, "alice", "alice@example.com")
+	if code := verifyEmail(t, server, waitForToken(t, mailbox, 1)); code != http.StatusOK {
+		t.Fatalf("verify = %d, want 200", code)
+	}
+
+	recorder := request(t, server, http.MethodPost, "/session/login", url.Values{"username": {"alice"}, "password": {testPassword}}, "")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("session login = %d %s", recorder.Code, recorder.Body.String())
+	}
+	sessionCookies := recorder.Result().Cookies()
+
+	for range 2 {
+		recorder := request(t, server, http.MethodPost, "/api/auth/password/reset-request", url.Values{"email": {"ALICE@example.com"}}, "")
+		if recorder.Code != http.StatusAccepted {
+			t.Fatalf("reset request = %d, want 202", recorder.Code)
+		}
+	}
+	first, second := waitForToken(t, mailbox, 2), waitForToken(t, mailbox, 3)
+
+	const newPassword = "a different horse battery staple"
+	if code := resetPassword(t, server, first, newPassword); code != http.StatusNoContent {
+		t.Fatalf("reset = %d, want 204", code)
+	}
+	if code := resetPassword(t, server, first, "yet another horse battery staple"); code != http.StatusBadRequest {
+		t.Fatalf("reused reset token = %d, want 400", code)
+	}
+	if code := resetPassword(t, server, second, "yet another horse battery staple"); code != http.StatusBadRequest {
+		t.Fatalf("reset token issued before the reset = %d, want 400", code)
+	}
+
+	recorder = request(t, server, http.MethodPost, "/api/auth/login", url.Values{"username": {"alice"}, "password": {newPassword}}, "")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("login with the new password = %d, want 200", recorder.Code)
+	}
+	if code := request(t, server, http.MethodPost, "/api/auth/refresh", url.Values{"refresh_token": {pair.RefreshJWT}}, "").Code; code != http.StatusUnauthorized {
+		t.Fatalf("refresh after reset = %d, want 401", code)
+	}
+	if code := request(t, server, http.MethodGet, "/session/me", nil, "", sessionCookies...).Code; code != http.StatusUnauthorized {
+		t.Fatalf("cookie session after reset = %d, want 401", code)
+	}
+}
+
+func TestPasswordResetRequestWithoutVerifiedEmail(t *testing.T) {
+	server, mailbox := newTestServer(t, withMail)
+	registerWithEmail(t, server, "alice", "alice@example.com")
+	waitForToken(t, mailbox, 1)
+
+	for _, email := range []string{"alice@example.com", "bob@example.com", "not an email"} {
+		recorder := request(t, server, http.MethodPost, "/api/auth/password/reset-request", url.Values{"email": {email}}, "")
+		if recorder.Code != http.StatusAccepted {
+			t.Fatalf("reset request for %q = %d, want 202", email, recorder.Code)
+		}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if messages := mailbox.Messages(); len(messages) != 1 {
+		t.Fatalf("%d messages sent, want only the verification email", len(messages))
+	}
+}
@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"github.com/IzomSoftware/GinWrapper/storage/redis"
+	"github.com/IzomSoftware/GinWrapper/storage/sql"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type sqlCollector struct {
+	storage           *sql.Storage
+	maxOpen           *prometheus.Desc
+	open              *prometheus.Desc
+	inUse             *prometheus.Desc
+	idle              *prometheus.Desc
+	waitCount         *prometheus.Desc
+	waitDuration      *prometheus.Desc
+	maxIdleClosed     *prometheus.Desc
+	maxIdleTimeClosed *prometheus.Desc
+	maxLifetimeClosed *prometheus.Desc
+}
+
+func newSQLDesc(name string, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, "sql", name), help, nil, nil)
+}
+
+func RegisterSQL(storage *sql.Storage) error {
+	return Registry.Register(&sqlCollector{
+		storage:           storage,
+		maxOpen:           newSQLDesc("max_open_connections", "Maximum number of open connections to the database."),
+		open:              newSQLDesc("open_connections", "The number of established connections both in use and idle."),
+		inUse:             newSQLDesc("in_use_connections", "The number of connections currently in use."),
+		idle:              newSQLDesc("idle_connections", "The number of idle connections."),
+		waitCount:         newSQLDesc("wait_count_total", "The total number of connections waited for."),
+		waitDuration:      newSQLDesc("wait_duration_seconds_total", "The total time blocked waiting for a new connection."),
+		maxIdleClosed:     newSQLDesc("max_idle_closed_total", "The total number of connections closed due to SetMaxIdleConns."),
+		maxIdleTimeClosed: newSQLDesc("max_idle_time_closed_total", "The total number of connections closed due to SetConnMaxIdleTime."),
+		maxLifetimeClosed: newSQLDesc("max_lifetime_closed_total", "The total number of connections closed due to SetConnMaxLifetime."),
+	})
+}
+
+func (C *sqlCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- C.maxOpen
+	descs <- C.open
+	descs <- C.inUse
+	descs <- C.idle
+	descs <- C.waitCount
+	descs <- C.waitDuration
+	descs <- C.maxIdleClosed
+	descs <- C.maxIdleTimeClosed
+	descs <- C.maxLifetimeClosed
+}
+
+func (C *sqlCollector) Collect(metrics chan<- prometheus.Metric) {
+	stats := C.storage.Stats()
+	metrics <- prometheus.MustNewConstMetric(C.maxOpen, prometheus.GaugeValue, float64(stats.MaxOpenConnections))
+	metrics <- prometheus.MustNewConstMetric(C.open, prometheus.GaugeValue, float64(stats.OpenConnections))
+	metrics <- prometheus.MustNewConstMetric(C.inUse, prometheus.GaugeValue, float64(stats.InUse))
+	metrics <- prometheus.MustNewConstMetric(C.idle, prometheus.GaugeValue, float64(stats.Idle))
+	metrics <- prometheus.MustNewConstMetric(C.waitCount, prometheus.CounterValue, float64(stats.WaitCount))
+	metrics <- prometheus.MustNewConstMetric(C.waitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds())
+	metrics <- prometheus.MustNewConstMetric(C.maxIdleClosed, prometheus.CounterValue, float64(stats.MaxIdleClosed))
+	metrics <- prometheus.MustNewConstMetric(C.maxIdleTimeClosed, prometheus.CounterValue, float64(stats.MaxIdleTimeClosed))
+	metrics <- prometheus.MustNewConstMetric(C.maxLifetimeClosed, prometheus.CounterValue, float64(stats.MaxLifetimeClosed))
+}
+
+type redisCollector struct {
+	storage  *redis.Storage
+	hits     *prometheus.Desc
+	misses   *prometheus.Desc
+	timeouts *prometheus.Desc
+	total    *prometheus.Desc
+	idle     *prometheus.Desc
+	stale    *prometheus.Desc
+}
+
+func newRedisDesc(name string, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, "redis", name), help, nil, nil)
+}
+
+func RegisterRedis(storage *redis.Storage) error {
+	return Registry.Register(&redisCollector{
+		storage:  storage,
+		hits:     newRedisDesc("pool_hits_total", "Number of times a free connection was found in the pool."),
+		misses:   newRedisDesc("pool_misses_total", "Number of times a free connection was not found in the pool."),
+		timeouts: newRedisDesc("pool_timeouts_total", "Number of times a wait timeout occurred."),
+		total:    newRedisDesc("pool_connections", "Number of total connections in the pool."),
+		idle:     newRedisDesc("pool_idle_connections", "Number of idle connections in the pool."),
+		stale:    newRedisDesc("pool_stale_connections_total", "Number of stale connections removed from the pool."),
+	})
+}
+
+func (C *redisCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- C.hits
+	descs <- C.misses
+	descs <- C.timeouts
+	descs <- C.total
+	descs <- C.idle
+	descs <- C.stale
+}
+
+func (C *redisCollector) Collect(metrics chan<- prometheus.Metric) {
+	stats := C.storage.PoolStats()
+	metrics <- prometheus.MustNewConstMetric(C.hits, prometheus.CounterValue, float64(stats.Hits))
+	metrics <- prometheus.MustNewConstMetric(C.misses, prometheus.CounterValue, float64(stats.Misses))
+	metrics <- prometheus.MustNewConstMetric(C.timeouts, prometheus.CounterValue, float64(stats.Timeouts))
+	metrics <- prometheus.MustNewConstMetric(C.total, prometheus.GaugeValue, float64(stats.TotalConns))
+	metrics <- prometheus.MustNewConstMetric(C.idle, prometheus.GaugeValue, float64(stats.IdleConns))
+	metrics <- prometheus.MustNewConstMetric(C.stale, prometheus.CounterValue, float64(stats.StaleConns))
+}
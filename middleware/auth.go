@@ -5,33 +5,71 @@ import (
 	"strings"
 
 	"github.com/IzomSoftware/GinWrapper/authentication"
+	"github.com/IzomSoftware/GinWrapper/logger"
+	"github.com/IzomSoftware/GinWrapper/metrics"
+	"github.com/IzomSoftware/GinWrapper/storage"
 	"github.com/gin-gonic/gin"
 )
 
-func Authentication(jwtManager *authentication.JWTManager) gin.HandlerFunc {
+var authLog = logger.Named("auth")
+
+func Authentication(jwtManager *authentication.JWTManager, sessionTracker *storage.SessionTracker) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if c.GetString("auth_method") == "client_certificate" {
+			c.Next()
+			return
+		}
+
 		header := c.GetHeader("Authorization")
 		if header == "" {
+			metrics.JWTValidationFailures.WithLabelValues("missing_header").Inc()
 			c.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
 
 		parts := strings.SplitN(header, " ", 2)
 		if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+			metrics.JWTValidationFailures.WithLabelValues("malformed_header").Inc()
 			c.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
 
 		claims, err := jwtManager.ValidateJWT(parts[1])
+		if err == nil && claims.TokenType != "access" {
+			err = authentication.ErrInvalidTokenType
+		}
 		if err != nil {
+			metrics.JWTValidationFailures.WithLabelValues(authentication.ValidationFailureReason(err)).Inc()
+			authLog.DebugContext(c.Request.Context(), "jwt validation failed", "ip", c.ClientIP(), "err", err)
 			c.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
 
+		if sessionTracker != nil {
+			if claims.SessionID == "" {
+				err = authentication.ErrSessionRevoked
+			} else {
+				err = sessionTracker.Validate(c.Request.Context(), claims.SessionID, claims.Username)
+			}
+			if err != nil {
+				metrics.JWTValidationFailures.WithLabelValues(authentication.ValidationFailureReason(err)).Inc()
+				authLog.DebugContext(c.Request.Context(), "jwt session rejected", "ip", c.ClientIP(), "err", err)
+				c.AbortWithStatus(http.StatusUnauthorized)
+				return
+			}
+
+			if err := sessionTracker.Touch(c.Request.Context(), claims.SessionID, c.ClientIP()); err != nil {
+				authLog.WarnContext(c.Request.Context(), "session touch failed", "err", err)
+			}
+			c.Set("session_id", claims.SessionID)
+		}
+
 		c.Set("claims", claims)
 		c.Set("uuid", claims.Uuid)
 		c.Set("username", claims.Username)
 		c.Set("token_type", claims.TokenType)
+		c.Set("auth_method", "jwt")
+		c.Request = c.Request.WithContext(logger.WithUsername(c.Request.Context(), claims.Username))
 		c.Next()
 	}
 }
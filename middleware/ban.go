@@ -3,33 +3,66 @@ package middleware
 import (
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
-	"github.com/IzomSoftware/GinWrapper/logger"
+	"github.com/IzomSoftware/GinWrapper/metrics"
 	"github.com/IzomSoftware/GinWrapper/storage/redis"
+	"github.com/IzomSoftware/GinWrapper/tracing"
 	"github.com/gin-gonic/gin"
 )
 
 func BanCheck(redis *redis.Storage) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx, span := tracing.Start(c.Request.Context(), "middleware.BanCheck")
 		ip := c.ClientIP()
-		banned, err := redis.Exists(fmt.Sprintf("ban:%s", ip))
+		banned, err := redis.WithContext(ctx).Exists(fmt.Sprintf("ban:%s", ip))
+		span.End()
 
 		if err != nil {
-			logger.Error("ban check failed for ip", ip, "err", err)
+			metrics.BanDecisions.WithLabelValues("error").Inc()
+			log.ErrorContext(c.Request.Context(), "ban check failed", "ip", ip, "err", err)
 			c.Next()
 			return
 		}
 
 		if banned {
+			metrics.BanDecisions.WithLabelValues("banned").Inc()
 			c.AbortWithStatus(http.StatusForbidden)
 			return
 		}
 
+		metrics.BanDecisions.WithLabelValues("allowed").Inc()
 		c.Next()
 	}
 }
 
+type Ban struct {
+	IP        string `json:"ip"`
+	ExpiresIn int64  `json:"expires_in_seconds"`
+}
+
 func BanIP(redis *redis.Storage, ip string, time time.Duration) error {
 	return redis.Set(fmt.Sprintf("ban:%s", ip), "1", time)
 }
+
+func UnbanIP(redis *redis.Storage, ip string) error {
+	return redis.Del(fmt.Sprintf("ban:%s", ip))
+}
+
+func ListBans(redis *redis.Storage) ([]Ban, error) {
+	keys, err := redis.Keys("ban:*")
+	if err != nil {
+		return nil, err
+	}
+
+	bans := make([]Ban, 0, len(keys))
+	for _, key := range keys {
+		ttl, err := redis.TTL(key)
+		if err != nil {
+			return nil, err
+		}
+		bans = append(bans, Ban{IP: strings.TrimPrefix(key, "ban:"), ExpiresIn: int64(ttl.Seconds())})
+	}
+	return bans, nil
+}
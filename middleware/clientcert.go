@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"crypto/x509"
+
+	"github.com/IzomSoftware/GinWrapper/logger"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdentityCommonName = "common_name"
+	IdentityDNSName    = "dns_san"
+	IdentityURI        = "uri_san"
+	IdentityEmail      = "email_san"
+)
+
+func ClientCertificate(identitySource string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0 || len(c.Request.TLS.VerifiedChains[0]) == 0 {
+			c.Next()
+			return
+		}
+
+		certificate := c.Request.TLS.VerifiedChains[0][0]
+		identity := certificateIdentity(certificate, identitySource)
+		if identity == "" {
+			authLog.WarnContext(c.Request.Context(), "client certificate has no usable identity", "subject", certificate.Subject.String(), "source", identitySource)
+			c.Next()
+			return
+		}
+
+		c.Set("client_certificate", certificate)
+		c.Set("uuid", identity)
+		c.Set("username", identity)
+		c.Set("auth_method", "client_certificate")
+		c.Request = c.Request.WithContext(logger.WithUsername(c.Request.Context(), identity))
+		c.Next()
+	}
+}
+
+func certificateIdentity(certificate *x509.Certificate, identitySource string) string {
+	switch identitySource {
+	case IdentityDNSName:
+		if len(certificate.DNSNames) > 0 {
+			return certificate.DNSNames[0]
+		}
+	case IdentityURI:
+		if len(certificate.URIs) > 0 {
+			return certificate.URIs[0].String()
+		}
+	case IdentityEmail:
+		if len(certificate.EmailAddresses) > 0 {
+			return certificate.EmailAddresses[0]
+		}
+	default:
+		return certificate.Subject.CommonName
+	}
+	return ""
+}
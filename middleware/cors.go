@@ -0,0 +1,232 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/IzomSoftware/GinWrapper/configuration"
+	"github.com/gin-gonic/gin"
+)
+
+var ErrCORSWildcardCredentials = fmt.Errorf("cors cannot allow any origin together with credentials")
+
+type originMatcher func(origin string) bool
+
+type corsPolicy struct {
+	anyOrigin        bool
+	origins          []originMatcher
+	methods          map[string]struct{}
+	allowedMethods   string
+	anyHeader        bool
+	headers          map[string]struct{}
+	exposedHeaders   string
+	allowCredentials bool
+	maxAge           string
+}
+
+type corsGroup struct {
+	prefix string
+	policy *corsPolicy
+}
+
+func CORS(configuration configuration.CORSProtection) (gin.HandlerFunc, error) {
+	defaultPolicy, err := newCORSPolicy(configuration.Default)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]corsGroup, 0, len(configuration.Groups))
+	for prefix, policyConfiguration := range configuration.Groups {
+		policy, err := newCORSPolicy(policyConfiguration)
+		if err != nil {
+			return nil, fmt.Errorf("cors group %s: %w", prefix, err)
+		}
+		groups = append(groups, corsGroup{prefix: prefix, policy: policy})
+	}
+	sort.Slice(groups, func(i, j int) bool { return len(groups[i].prefix) > len(groups[j].prefix) })
+
+	return func(c *gin.Context) {
+		policy := defaultPolicy
+		for _, group := range groups {
+			if matchesPathPrefix(c.Request.URL.Path, group.prefix) {
+				policy = group.policy
+				break
+			}
+		}
+		policy.handle(c)
+	}, nil
+}
+
+func matchesPathPrefix(path string, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+func newCORSPolicy(configuration configuration.CORSPolicy) (*corsPolicy, error) {
+	policy := &corsPolicy{
+		methods:          map[string]struct{}{},
+		headers:          map[string]struct{}{},
+		allowCredentials: configuration.AllowCredentials,
+		exposedHeaders:   strings.Join(configuration.ExposedHeaders, ", "),
+	}
+
+	for _, origin := range configuration.AllowedOrigins {
+		if origin == "*" {
+			policy.anyOrigin = true
+			continue
+		}
+		matcher, err := newOriginMatcher(origin)
+		if err != nil {
+			return nil, err
+		}
+		policy.origins = append(policy.origins, matcher)
+	}
+
+	if policy.anyOrigin && policy.allowCredentials {
+		return nil, ErrCORSWildcardCredentials
+	}
+
+	methods := make([]string, 0, len(configuration.AllowedMethods))
+	for _, method := range configuration.AllowedMethods {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		policy.methods[method] = struct{}{}
+		methods = append(methods, method)
+	}
+	policy.allowedMethods = strings.Join(methods, ", ")
+
+	for _, header := range configuration.AllowedHeaders {
+		if header == "*" {
+			policy.anyHeader = true
+			continue
+		}
+		policy.headers[http.CanonicalHeaderKey(strings.TrimSpace(header))] = struct{}{}
+	}
+
+	if configuration.MaxAgeSeconds > 0 {
+		policy.maxAge = strconv.Itoa(configuration.MaxAgeSeconds)
+	}
+	return policy, nil
+}
+
+func newOriginMatcher(pattern string) (originMatcher, error) {
+	if expression, ok := strings.CutPrefix(pattern, "regex:"); ok {
+		compiled, err := regexp.Compile("^(?:" + expression + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("cors origin pattern %q: %w", pattern, err)
+		}
+		return compiled.MatchString, nil
+	}
+
+	if scheme, host, ok := strings.Cut(pattern, "://*."); ok {
+		port := ""
+		if hostname, hostPort, err := net.SplitHostPort(host); err == nil {
+			host, port = hostname, hostPort
+		}
+		suffix := "." + strings.ToLower(host)
+		return func(origin string) bool {
+			parsed, err := url.Parse(origin)
+			if err != nil || !strings.EqualFold(parsed.Scheme, scheme) {
+				return false
+			}
+			if port != "" && parsed.Port() != port {
+				return false
+			}
+			originHost := strings.ToLower(parsed.Hostname())
+			return strings.HasSuffix(originHost, suffix) && len(originHost) > len(suffix)
+		}, nil
+	}
+
+	return func(origin string) bool {
+		return strings.EqualFold(origin, pattern)
+	}, nil
+}
+
+func (P *corsPolicy) isOriginAllowed(origin string) bool {
+	if P.anyOrigin {
+		return true
+	}
+	for _, matcher := range P.origins {
+		if matcher(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (P *corsPolicy) areHeadersAllowed(requested string) bool {
+	if P.anyHeader || requested == "" {
+		return true
+	}
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+		if _, ok := P.headers[http.CanonicalHeaderKey(header)]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (P *corsPolicy) handle(c *gin.Context) {
+	origin := c.GetHeader("Origin")
+	if origin == "" {
+		c.Next()
+		return
+	}
+
+	c.Writer.Header().Add("Vary", "Origin")
+	preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+	if !P.isOriginAllowed(origin) {
+		if preflight {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Next()
+		return
+	}
+
+	if P.anyOrigin {
+		c.Header("Access-Control-Allow-Origin", "*")
+	} else {
+		c.Header("Access-Control-Allow-Origin", origin)
+	}
+	if P.allowCredentials {
+		c.Header("Access-Control-Allow-Credentials", "true")
+	}
+
+	if !preflight {
+		if P.exposedHeaders != "" {
+			c.Header("Access-Control-Expose-Headers", P.exposedHeaders)
+		}
+		c.Next()
+		return
+	}
+
+	c.Writer.Header().Add("Vary", "Access-Control-Request-Method")
+	c.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+
+	requestedMethod := strings.ToUpper(c.GetHeader("Access-Control-Request-Method"))
+	requestedHeaders := c.GetHeader("Access-Control-Request-Headers")
+	if _, ok := P.methods[requestedMethod]; !ok || !P.areHeadersAllowed(requestedHeaders) {
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	c.Header("Access-Control-Allow-Methods", P.allowedMethods)
+	if requestedHeaders != "" {
+		c.Header("Access-Control-Allow-Headers", requestedHeaders)
+	}
+	if P.maxAge != "" {
+		c.Header("Access-Control-Max-Age", P.maxAge)
+	}
+	c.AbortWithStatus(http.StatusNoContent)
+}
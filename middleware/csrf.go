@@ -0,0 +1,201 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/IzomSoftware/GinWrapper/authentication"
+	"github.com/IzomSoftware/GinWrapper/configuration"
+	"github.com/IzomSoftware/GinWrapper/storage/redis"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	CSRFModeSynchronizer = "synchronizer"
+	CSRFModeDoubleSubmit = "double_submit"
+)
+
+var ErrUnknownCSRFMode = fmt.Errorf("unknown csrf mode")
+var ErrCSRFRequiresRedis = fmt.Errorf("synchronizer csrf tokens require redis")
+
+type csrfProtection struct {
+	configuration configuration.CSRFProtection
+	redis         *redis.Storage
+	secret        []byte
+	sessionCookie string
+	ttl           time.Duration
+}
+
+func CSRF(redis *redis.Storage, configuration configuration.CSRFProtection, secret string, sessionCookie string) (gin.HandlerFunc, error) {
+	switch configuration.Mode {
+	case CSRFModeSynchronizer:
+		if redis == nil {
+			return nil, ErrCSRFRequiresRedis
+		}
+	case CSRFModeDoubleSubmit:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCSRFMode, configuration.Mode)
+	}
+
+	protection := &csrfProtection{
+		configuration: configuration,
+		redis:         redis,
+		secret:        authentication.DeriveKey(secret, "csrf"),
+		sessionCookie: sessionCookie,
+		ttl:           time.Duration(configuration.TokenTTLSeconds) * time.Second,
+	}
+	return protection.handle, nil
+}
+
+func CSRFTemplateFuncs(fieldName string) template.FuncMap {
+	return template.FuncMap{
+		"csrfField": func(token string) template.HTML {
+			return template.HTML(fmt.Sprintf(`<input type="hidden" name="%s" value="%s">`, template.HTMLEscapeString(fieldName), template.HTMLEscapeString(token)))
+		},
+	}
+}
+
+func (P *csrfProtection) isExempt(c *gin.Context) bool {
+	for _, prefix := range P.configuration.ExemptPaths {
+		if strings.HasPrefix(c.Request.URL.Path, prefix) {
+			return true
+		}
+	}
+
+	if P.configuration.ExemptBearer {
+		parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+		if len(parts) == 2 && strings.EqualFold(parts[0], "Bearer") {
+			return true
+		}
+	}
+	return false
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func (P *csrfProtection) handle(c *gin.Context) {
+	if P.isExempt(c) {
+		c.Next()
+		return
+	}
+
+	expected, err := P.currentToken(c)
+	if err != nil {
+		log.ErrorContext(c.Request.Context(), "csrf token lookup failed", "err", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if !isSafeMethod(c.Request.Method) {
+		submitted := c.GetHeader(P.configuration.HeaderName)
+		if submitted == "" {
+			submitted = c.PostForm(P.configuration.FieldName)
+		}
+
+		if expected == "" || submitted == "" || subtle.ConstantTimeCompare([]byte(submitted), []byte(expected)) != 1 || !P.verify(c, submitted) {
+			log.WarnContext(c.Request.Context(), "csrf validation failed", "ip", c.ClientIP(), "path", c.Request.URL.Path)
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+	}
+
+	if expected == "" && (c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead) {
+		if expected, err = P.issueToken(c); err != nil {
+			log.ErrorContext(c.Request.Context(), "csrf token issue failed", "err", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	c.Set("csrf_token", expected)
+	c.Next()
+}
+
+func (P *csrfProtection) sessionID(c *gin.Context) string {
+	if P.sessionCookie == "" {
+		return ""
+	}
+	session, _ := c.Cookie(P.sessionCookie)
+	return session
+}
+
+func (P *csrfProtection) sign(c *gin.Context, value string) string {
+	mac := hmac.New(sha256.New, P.secret)
+	mac.Write([]byte(P.sessionID(c)))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (P *csrfProtection) verify(c *gin.Context, token string) bool {
+	if P.configuration.Mode != CSRFModeDoubleSubmit {
+		return true
+	}
+	value, signature, ok := strings.Cut(token, ".")
+	return ok && hmac.Equal([]byte(signature), []byte(P.sign(c, value)))
+}
+
+func (P *csrfProtection) storageKey(id string) string {
+	return fmt.Sprintf("csrf:%s", id)
+}
+
+func (P *csrfProtection) currentToken(c *gin.Context) (string, error) {
+	cookie, err := c.Cookie(P.configuration.CookieName)
+	if err != nil || cookie == "" {
+		return "", nil
+	}
+
+	if P.configuration.Mode == CSRFModeDoubleSubmit {
+		if !P.verify(c, cookie) {
+			return "", nil
+		}
+		return cookie, nil
+	}
+
+	token, err := P.redis.WithContext(c.Request.Context()).Get(P.storageKey(cookie))
+	if err == redis.Nil {
+		return "", nil
+	}
+	return token, err
+}
+
+func (P *csrfProtection) issueToken(c *gin.Context) (string, error) {
+	token, err := authentication.GenerateRandomSecret(32)
+	if err != nil {
+		return "", err
+	}
+
+	cookieValue := token
+	httpOnly := true
+	if P.configuration.Mode == CSRFModeDoubleSubmit {
+		token = token + "." + P.sign(c, token)
+		cookieValue = token
+		httpOnly = false
+	} else {
+		id, err := authentication.GenerateRandomSecret(32)
+		if err != nil {
+			return "", err
+		}
+		if err := P.redis.WithContext(c.Request.Context()).Set(P.storageKey(id), token, P.ttl); err != nil {
+			return "", err
+		}
+		cookieValue = id
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(P.configuration.CookieName, cookieValue, int(P.ttl.Seconds()), "/", "", P.configuration.SecureCookie, httpOnly)
+	return token, nil
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"fmt"
+
+	"github.com/IzomSoftware/GinWrapper/configuration"
+	"github.com/gin-gonic/gin"
+)
+
+func HSTS(configuration configuration.HSTS) gin.HandlerFunc {
+	value := fmt.Sprintf("max-age=%d", configuration.MaxAgeSeconds)
+	if configuration.IncludeSubdomains {
+		value += "; includeSubDomains"
+	}
+	if configuration.Preload {
+		value += "; preload"
+	}
+
+	return func(c *gin.Context) {
+		if c.Request.TLS != nil {
+			c.Header("Strict-Transport-Security", value)
+		}
+		c.Next()
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+var log = logger.Named("middleware")
+
 func Logging() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		logger.LogConnection(c)
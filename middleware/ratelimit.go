@@ -5,7 +5,9 @@ import (
 	"time"
 
 	"github.com/IzomSoftware/GinWrapper/configuration"
+	"github.com/IzomSoftware/GinWrapper/metrics"
 	"github.com/IzomSoftware/GinWrapper/storage/redis"
+	"github.com/IzomSoftware/GinWrapper/tracing"
 	"github.com/gin-gonic/gin"
 )
 
@@ -41,18 +43,25 @@ func RateLimit(redis *redis.Storage, configuration configuration.RateLimitProtec
 		ip := c.ClientIP()
 		now := time.Now().UnixMilli()
 
-		result, err := redis.RunScript(script, []string{ip}, configuration.Rate, configuration.Window*1000, now).Int64()
+		ctx, span := tracing.Start(c.Request.Context(), "middleware.RateLimit")
+		result, err := redis.WithContext(ctx).RunScript(script, []string{ip}, configuration.Rate, configuration.Window*1000, now).Int64()
+		span.End()
 
 		if err != nil {
+			metrics.RateLimitDecisions.WithLabelValues("error").Inc()
+			log.ErrorContext(c.Request.Context(), "rate limit check failed", "ip", ip, "err", err)
 			c.Next()
 			return
 		}
 
 		if result == 1 {
+			metrics.RateLimitDecisions.WithLabelValues("limited").Inc()
+			log.DebugContext(c.Request.Context(), "rate limited", "ip", ip)
 			c.AbortWithStatus(http.StatusTooManyRequests)
 			return
 		}
 
+		metrics.RateLimitDecisions.WithLabelValues("allowed").Inc()
 		c.Next()
 	}
 }
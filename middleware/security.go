@@ -0,0 +1,156 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/IzomSoftware/GinWrapper/configuration"
+	"github.com/gin-gonic/gin"
+)
+
+const noncePlaceholder = "{nonce}"
+
+const maxCSPReportSize = 16 * 1024
+
+func SecurityHeaders(configuration configuration.SecurityHeaders) gin.HandlerFunc {
+	policy := configuration.ContentSecurityPolicy
+	if policy != "" && configuration.CSPReportPath != "" {
+		policy += "; report-uri " + configuration.CSPReportPath
+	}
+	usesNonce := strings.Contains(policy, noncePlaceholder)
+
+	policyHeader := "Content-Security-Policy"
+	if configuration.CSPReportOnly {
+		policyHeader = "Content-Security-Policy-Report-Only"
+	}
+
+	return func(c *gin.Context) {
+		if policy != "" {
+			headerValue := policy
+			if usesNonce {
+				nonce, err := generateNonce()
+				if err != nil {
+					c.AbortWithStatus(http.StatusInternalServerError)
+					return
+				}
+				c.Set("csp_nonce", nonce)
+				headerValue = strings.ReplaceAll(policy, noncePlaceholder, nonce)
+			}
+			c.Header(policyHeader, headerValue)
+		}
+
+		if configuration.FrameOptions != "" {
+			c.Header("X-Frame-Options", configuration.FrameOptions)
+		}
+		if configuration.ReferrerPolicy != "" {
+			c.Header("Referrer-Policy", configuration.ReferrerPolicy)
+		}
+		if configuration.PermissionsPolicy != "" {
+			c.Header("Permissions-Policy", configuration.PermissionsPolicy)
+		}
+		if configuration.ContentTypeNosniff {
+			c.Header("X-Content-Type-Options", "nosniff")
+		}
+		if configuration.CrossOriginOpenerPolicy != "" {
+			c.Header("Cross-Origin-Opener-Policy", configuration.CrossOriginOpenerPolicy)
+		}
+
+		c.Next()
+	}
+}
+
+func generateNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+type cspViolation struct {
+	DocumentURI        string `json:"document-uri"`
+	BlockedURI         string `json:"blocked-uri"`
+	ViolatedDirective  string `json:"violated-directive"`
+	EffectiveDirective string `json:"effective-directive"`
+	SourceFile         string `json:"source-file"`
+	LineNumber         int    `json:"line-number"`
+	Disposition        string `json:"disposition"`
+}
+
+type reportingAPIViolation struct {
+	DocumentURL        string `json:"documentURL"`
+	BlockedURL         string `json:"blockedURL"`
+	EffectiveDirective string `json:"effectiveDirective"`
+	SourceFile         string `json:"sourceFile"`
+	LineNumber         int    `json:"lineNumber"`
+	Disposition        string `json:"disposition"`
+}
+
+func CSPReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxCSPReportSize))
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			c.AbortWithStatus(http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		var violations []cspViolation
+		if strings.HasPrefix(c.ContentType(), "application/reports+json") {
+			var reports []struct {
+				Type string                `json:"type"`
+				Body reportingAPIViolation `json:"body"`
+			}
+			if err := json.Unmarshal(body, &reports); err != nil {
+				c.AbortWithStatus(http.StatusBadRequest)
+				return
+			}
+			for _, report := range reports {
+				if report.Type != "csp-violation" {
+					continue
+				}
+				violations = append(violations, cspViolation{
+					DocumentURI:        report.Body.DocumentURL,
+					BlockedURI:         report.Body.BlockedURL,
+					EffectiveDirective: report.Body.EffectiveDirective,
+					SourceFile:         report.Body.SourceFile,
+					LineNumber:         report.Body.LineNumber,
+					Disposition:        report.Body.Disposition,
+				})
+			}
+		} else {
+			var report struct {
+				Report cspViolation `json:"csp-report"`
+			}
+			if err := json.Unmarshal(body, &report); err != nil {
+				c.AbortWithStatus(http.StatusBadRequest)
+				return
+			}
+			violations = append(violations, report.Report)
+		}
+
+		for _, violation := range violations {
+			log.WarnContext(c.Request.Context(), "csp violation",
+				"ip", c.ClientIP(),
+				"document_uri", violation.DocumentURI,
+				"blocked_uri", violation.BlockedURI,
+				"violated_directive", violation.ViolatedDirective,
+				"effective_directive", violation.EffectiveDirective,
+				"source_file", violation.SourceFile,
+				"line_number", violation.LineNumber,
+				"disposition", violation.Disposition,
+			)
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
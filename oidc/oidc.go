@@ -0,0 +1,362 @@
+package oidc
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/IzomSoftware/GinWrapper/authentication"
+	"github.com/IzomSoftware/GinWrapper/configuration"
+	"github.com/IzomSoftware/GinWrapper/logger"
+	"github.com/IzomSoftware/GinWrapper/storage"
+	"github.com/IzomSoftware/GinWrapper/storage/redis"
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var log = logger.Named("oidc")
+
+var (
+	ErrOIDCRequiresStorage = fmt.Errorf("oidc requires sql and redis storage")
+	ErrUnknownProvider     = fmt.Errorf("unknown oidc provider")
+	ErrDuplicateProvider   = fmt.Errorf("duplicate oidc provider")
+	ErrStateNotFound       = fmt.Errorf("oidc state not found or expired")
+	ErrStateMismatch       = fmt.Errorf("oidc state belongs to another provider")
+	ErrStateNotBound       = fmt.Errorf("oidc state was not started by this browser")
+	ErrMissingIDToken      = fmt.Errorf("token response did not include an id_token")
+	ErrNonceMismatch       = fmt.Errorf("id token nonce mismatch")
+	ErrMissingUsername     = fmt.Errorf("id token does not carry the username claim")
+	ErrAccountNotLinked    = fmt.Errorf("no local account is linked to this identity")
+	ErrIdentityLinked      = fmt.Errorf("identity is already linked to another account")
+	ErrIdentityNotFound    = fmt.Errorf("identity not found")
+	ErrUsernameTaken       = fmt.Errorf("username belongs to an existing local account")
+	ErrLastSignInMethod    = fmt.Errorf("cannot unlink the only sign-in method")
+)
+
+type Provider struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+type Result struct {
+	Username string
+	Provider string
+	Subject  string
+	Linked   bool
+	Created  bool
+	Linking  bool
+}
+
+type claims struct {
+	Subject       string `json:"sub"`
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+type state struct {
+	Provider     string `json:"provider"`
+	Nonce        string `json:"nonce"`
+	Verifier     string `json:"verifier"`
+	LinkUsername string `json:"link_username,omitempty"`
+}
+
+type provider struct {
+	configuration configuration.OIDCProvider
+
+	mutex    sync.Mutex
+	oauth2   *oauth2.Config
+	verifier *gooidc.IDTokenVerifier
+}
+
+type Manager struct {
+	providers map[string]*provider
+	store     *store
+	emails    *storage.EmailStore
+	redis     *redis.Storage
+	policy    *authentication.PasswordPolicy
+	stateTTL  time.Duration
+}
+
+func NewManager(storage *storage.Storage, configuration configuration.OIDC, policy *authentication.PasswordPolicy) (*Manager, error) {
+	if storage.SQL == nil || storage.Redis == nil {
+		return nil, ErrOIDCRequiresStorage
+	}
+	if err := storage.SQL.ExecuteUpdate(schema); err != nil {
+		return nil, err
+	}
+
+	emails, err := storage.Emails()
+	if err != nil {
+		return nil, err
+	}
+
+	providers := make(map[string]*provider, len(configuration.Providers))
+	for _, providerConfiguration := range configuration.Providers {
+		if _, ok := providers[providerConfiguration.Name]; ok {
+			return nil, fmt.Errorf("%w: %q", ErrDuplicateProvider, providerConfiguration.Name)
+		}
+		if len(providerConfiguration.Scopes) == 0 {
+			providerConfiguration.Scopes = []string{gooidc.ScopeOpenID, "profile", "email"}
+		}
+		if providerConfiguration.UsernameClaim == "" {
+			providerConfiguration.UsernameClaim = "preferred_username"
+		}
+		if providerConfiguration.DisplayName == "" {
+			providerConfiguration.DisplayName = providerConfiguration.Name
+		}
+		providers[providerConfiguration.Name] = &provider{configuration: providerConfiguration}
+	}
+
+	return &Manager{
+		providers: providers,
+		store:     &store{sql: storage.SQL},
+		emails:    emails,
+		redis:     storage.Redis,
+		policy:    policy,
+		stateTTL:  time.Duration(configuration.StateTTLSeconds) * time.Second,
+	}, nil
+}
+
+func (P *provider) discover(ctx context.Context) (*oauth2.Config, *gooidc.IDTokenVerifier, error) {
+	P.mutex.Lock()
+	defer P.mutex.Unlock()
+
+	if P.oauth2 != nil {
+		return P.oauth2, P.verifier, nil
+	}
+
+	discovered, err := gooidc.NewProvider(ctx, P.configuration.IssuerURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	P.oauth2 = &oauth2.Config{
+		ClientID:     P.configuration.ClientID,
+		ClientSecret: P.configuration.ClientSecret,
+		RedirectURL:  P.configuration.RedirectURL,
+		Endpoint:     discovered.Endpoint(),
+		Scopes:       P.configuration.Scopes,
+	}
+	P.verifier = discovered.Verifier(&gooidc.Config{ClientID: P.configuration.ClientID})
+	log.InfoContext(ctx, "provider discovered", "provider", P.configuration.Name, "issuer", P.configuration.IssuerURL)
+	return P.oauth2, P.verifier, nil
+}
+
+func (M *Manager) provider(name string) (*provider, error) {
+	provider, ok := M.providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return provider, nil
+}
+
+func (M *Manager) Providers() []Provider {
+	providers := make([]Provider, 0, len(M.providers))
+	for _, provider := range M.providers {
+		providers = append(providers, Provider{Name: provider.configuration.Name, DisplayName: provider.configuration.DisplayName})
+	}
+	sort.Slice(providers, func(i, j int) bool { return providers[i].Name < providers[j].Name })
+	return providers
+}
+
+func stateKey(id string) string {
+	return fmt.Sprintf("oidc:%s", id)
+}
+
+func (M *Manager) takeState(ctx context.Context, id string) (*state, error) {
+	encoded, err := M.redis.WithContext(ctx).GetDel(stateKey(id))
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrStateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var value state
+	if err := json.Unmarshal([]byte(encoded), &value); err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+func (M *Manager) StateTTL() time.Duration {
+	return M.stateTTL
+}
+
+func (M *Manager) Begin(ctx context.Context, providerName string, linkUsername string) (string, string, error) {
+	provider, err := M.provider(providerName)
+	if err != nil {
+		return "", "", err
+	}
+	config, _, err := provider.discover(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	id, err := authentication.GenerateRandomSecret(16)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := authentication.GenerateRandomSecret(16)
+	if err != nil {
+		return "", "", err
+	}
+
+	value := state{Provider: providerName, Nonce: nonce, Verifier: oauth2.GenerateVerifier(), LinkUsername: linkUsername}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", "", err
+	}
+	if err := M.redis.WithContext(ctx).Set(stateKey(id), encoded, M.stateTTL); err != nil {
+		return "", "", err
+	}
+
+	return config.AuthCodeURL(id, gooidc.Nonce(nonce), oauth2.S256ChallengeOption(value.Verifier)), id, nil
+}
+
+func (M *Manager) Finish(ctx context.Context, providerName string, stateID string, browserState string, code string) (*Result, error) {
+	provider, err := M.provider(providerName)
+	if err != nil {
+		return nil, err
+	}
+	if browserState == "" || subtle.ConstantTimeCompare([]byte(browserState), []byte(stateID)) != 1 {
+		return nil, ErrStateNotBound
+	}
+	value, err := M.takeState(ctx, stateID)
+	if err != nil {
+		return nil, err
+	}
+	if value.Provider != providerName {
+		return nil, ErrStateMismatch
+	}
+
+	config, verifier, err := provider.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := config.Exchange(ctx, code, oauth2.VerifierOption(value.Verifier))
+	if err != nil {
+		return nil, err
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, ErrMissingIDToken
+	}
+
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var identity claims
+	if err := idToken.Claims(&identity); err != nil {
+		return nil, err
+	}
+	if identity.Nonce != value.Nonce {
+		return nil, ErrNonceMismatch
+	}
+
+	if value.LinkUsername != "" {
+		if err := M.store.link(ctx, providerName, identity.Subject, value.LinkUsername, identity.Email); err != nil {
+			return nil, err
+		}
+		log.InfoContext(ctx, "identity linked", "provider", providerName, "username", value.LinkUsername)
+		return &Result{Username: value.LinkUsername, Provider: providerName, Subject: identity.Subject, Linked: true, Linking: true}, nil
+	}
+
+	username, err := M.store.username(ctx, providerName, identity.Subject)
+	if err == nil {
+		return &Result{Username: username, Provider: providerName, Subject: identity.Subject}, nil
+	}
+	if !errors.Is(err, ErrIdentityNotFound) {
+		return nil, err
+	}
+
+	if provider.configuration.LinkByVerifiedEmail && identity.EmailVerified && identity.Email != "" {
+		username, err := M.verifiedEmailOwner(ctx, identity.Email)
+		if err != nil {
+			return nil, err
+		}
+		if username != "" {
+			if err := M.store.link(ctx, providerName, identity.Subject, username, identity.Email); err != nil {
+				return nil, err
+			}
+			log.InfoContext(ctx, "identity linked by verified email", "provider", providerName, "username", username)
+			return &Result{Username: username, Provider: providerName, Subject: identity.Subject, Linked: true}, nil
+		}
+	}
+
+	if !provider.configuration.AutoCreate {
+		return nil, ErrAccountNotLinked
+	}
+
+	var extra map[string]any
+	if err := idToken.Claims(&extra); err != nil {
+		return nil, err
+	}
+	username, _ = extra[provider.configuration.UsernameClaim].(string)
+	if username == "" {
+		return nil, ErrMissingUsername
+	}
+	if M.policy != nil {
+		if err := M.policy.ValidateUsername(username); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := M.store.create(ctx, providerName, identity.Subject, username, identity.Email); err != nil {
+		return nil, err
+	}
+	if identity.EmailVerified && identity.Email != "" {
+		if err := M.adoptEmail(ctx, username, identity.Email); err != nil {
+			log.WarnContext(ctx, "provider email not adopted", "username", username, "err", err)
+		}
+	}
+
+	log.InfoContext(ctx, "account created from identity", "provider", providerName, "username", username)
+	return &Result{Username: username, Provider: providerName, Subject: identity.Subject, Linked: true, Created: true}, nil
+}
+
+func (M *Manager) verifiedEmailOwner(ctx context.Context, email string) (string, error) {
+	username, err := M.emails.UsernameForEmail(ctx, email)
+	if errors.Is(err, authentication.ErrEmailNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	current, err := M.emails.Email(ctx, username)
+	if err != nil {
+		return "", err
+	}
+	if !current.Verified {
+		return "", nil
+	}
+	return username, nil
+}
+
+func (M *Manager) adoptEmail(ctx context.Context, username string, email string) error {
+	if _, err := M.emails.UsernameForEmail(ctx, email); !errors.Is(err, authentication.ErrEmailNotFound) {
+		return err
+	}
+	if err := M.emails.SetPending(ctx, username, email); err != nil {
+		return err
+	}
+	return M.emails.MarkVerified(ctx, username, email)
+}
+
+func (M *Manager) Identities(ctx context.Context, username string) ([]Identity, error) {
+	return M.store.list(ctx, username)
+}
+
+func (M *Manager) Unlink(ctx context.Context, username string, providerName string) error {
+	return M.store.unlink(ctx, username, providerName)
+}
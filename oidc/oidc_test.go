@@ -0,0 +1,428 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IzomSoftware/GinWrapper/authentication"
+	"github.com/IzomSoftware/GinWrapper/configuration"
+	"github.com/IzomSoftware/GinWrapper/storage"
+	"github.com/IzomSoftware/GinWrapper/storage/storagetest"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testProvider     = "corp"
+	testClientID     = "app"
+	testClientSecret = "s3cret"
+)
+
+const testSchema = `
+	CREATE TABLE IF NOT EXISTS Users (
+		username TEXT PRIMARY KEY,
+		hash TEXT NOT NULL
+	);
+`
+
+type grant struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+type issuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mutex  sync.Mutex
+	grants map[string]grant
+}
+
+func newIssuer(t *testing.T) *issuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := &issuer{key: key, grants: map[string]grant{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", issuer.discovery)
+	mux.HandleFunc("/jwks", issuer.jwks)
+	mux.HandleFunc("/token", issuer.token)
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func (I *issuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                I.server.URL,
+		"authorization_endpoint":                I.server.URL + "/authorize",
+		"token_endpoint":                        I.server.URL + "/token",
+		"jwks_uri":                              I.server.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (I *issuer) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(I.key.PublicKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(I.key.PublicKey.E)).Bytes()),
+		}},
+	})
+}
+
+func (I *issuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != testClientID || clientSecret != testClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	I.mutex.Lock()
+	granted, ok := I.grants[r.PostForm.Get("code")]
+	delete(I.grants, r.PostForm.Get("code"))
+	I.mutex.Unlock()
+
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(challenge[:]) != granted.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, granted.claims)
+	token.Header["kid"] = "test"
+	idToken, err := token.SignedString(I.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func (I *issuer) authorize(t *testing.T, authorizationURL string, claims jwt.MapClaims) (string, string) {
+	t.Helper()
+	parsed, err := url.Parse(authorizationURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("authorization url %q does not carry a S256 code challenge", authorizationURL)
+	}
+
+	granted := jwt.MapClaims{
+		"iss":   I.server.URL,
+		"aud":   testClientID,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": query.Get("nonce"),
+	}
+	for key, value := range claims {
+		granted[key] = value
+	}
+
+	code, err := authentication.GenerateRandomSecret(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	I.mutex.Lock()
+	I.grants[code] = grant{challenge: query.Get("code_challenge"), claims: granted}
+	I.mutex.Unlock()
+	return query.Get("state"), code
+}
+
+func newTestManager(t *testing.T, issuer *issuer) (*Manager, *storage.Storage) {
+	t.Helper()
+	storage := storagetest.New(t, testSchema)
+	manager, err := NewManager(storage, configuration.OIDC{
+		Enabled:         true,
+		StateTTLSeconds: 600,
+		Providers: []configuration.OIDCProvider{{
+			Name:         testProvider,
+			IssuerURL:    issuer.server.URL,
+			ClientID:     testClientID,
+			ClientSecret: testClientSecret,
+			RedirectURL:  "http://localhost/api/auth/oidc/corp/callback",
+			AutoCreate:   true,
+		}},
+	}, &authentication.PasswordPolicy{
+		MinUsernameLength: 3,
+		MaxUsernameLength: 64,
+		UsernamePattern:   regexp.MustCompile(`^[a-zA-Z0-9._-]+$`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return manager, storage
+}
+
+func addUser(t *testing.T, storage *storage.Storage, username string) {
+	t.Helper()
+	if err := storage.SQL.ExecuteUpdate("INSERT INTO Users (username, hash) VALUES (?, 'hash')", username); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func userExists(t *testing.T, storage *storage.Storage, username string) bool {
+	t.Helper()
+	var count int
+	if err := storage.SQL.QueryRow("SELECT COUNT(*) FROM Users WHERE username = ?", username).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	return count > 0
+}
+
+func login(t *testing.T, manager *Manager, issuer *issuer, linkUsername string, claims jwt.MapClaims) (*Result, error) {
+	t.Helper()
+	ctx := context.Background()
+	authorizationURL, stateID, err := manager.Begin(ctx, testProvider, linkUsername)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, code := issuer.authorize(t, authorizationURL, claims)
+	if state != stateID {
+		t.Fatalf("authorization url state = %q, want %q", state, stateID)
+	}
+	return manager.Finish(ctx, testProvider, state, stateID, code)
+}
+
+func TestLoginCreatesAndReusesAccount(t *testing.T) {
+	issuer := newIssuer(t)
+	manager, storage := newTestManager(t, issuer)
+
+	result, err := login(t, manager, issuer, "", jwt.MapClaims{"sub": "subject-1", "preferred_username": "alice"})
+	if err != nil {
+		t.Fatalf("Finish: %v", err)
+	}
+	if !result.Created || result.Username != "alice" {
+		t.Fatalf("result = %+v, want a created alice account", result)
+	}
+	if !userExists(t, storage, "alice") {
+		t.Fatal("alice was not created")
+	}
+
+	result, err = login(t, manager, issuer, "", jwt.MapClaims{"sub": "subject-1", "preferred_username": "renamed"})
+	if err != nil {
+		t.Fatalf("Finish: %v", err)
+	}
+	if result.Created || result.Username != "alice" {
+		t.Fatalf("result = %+v, want the existing alice account", result)
+	}
+}
+
+func TestStateIsBoundToBrowser(t *testing.T) {
+	issuer := newIssuer(t)
+	manager, storage := newTestManager(t, issuer)
+	addUser(t, storage, "mallory")
+
+	ctx := context.Background()
+	authorizationURL, _, err := manager.Begin(ctx, testProvider, "mallory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, code := issuer.authorize(t, authorizationURL, jwt.MapClaims{"sub": "victim"})
+
+	other, _, err := manager.Begin(ctx, testProvider, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherState, _ := issuer.authorize(t, other, jwt.MapClaims{"sub": "victim"})
+
+	for _, browserState := range []string{"", otherState} {
+		if _, err := manager.Finish(ctx, testProvider, state, browserState, code); !errors.Is(err, ErrStateNotBound) {
+			t.Fatalf("Finish with browser state %q = %v, want ErrStateNotBound", browserState, err)
+		}
+	}
+
+	identities, err := manager.Identities(ctx, "mallory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(identities) != 0 {
+		t.Fatalf("identities = %+v, want none", identities)
+	}
+}
+
+func TestStateIsSingleUse(t *testing.T) {
+	issuer := newIssuer(t)
+	manager, _ := newTestManager(t, issuer)
+
+	ctx := context.Background()
+	authorizationURL, stateID, err := manager.Begin(ctx, testProvider, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, code := issuer.authorize(t, authorizationURL, jwt.MapClaims{"sub": "subject-1", "preferred_username": "alice"})
+	if _, err := manager.Finish(ctx, testProvider, state, stateID, code); err != nil {
+		t.Fatalf("Finish: %v", err)
+	}
+	if _, err := manager.Finish(ctx, testProvider, state, stateID, code); !errors.Is(err, ErrStateNotFound) {
+		t.Fatalf("second Finish = %v, want ErrStateNotFound", err)
+	}
+}
+
+func TestNonceMismatch(t *testing.T) {
+	issuer := newIssuer(t)
+	manager, storage := newTestManager(t, issuer)
+
+	_, err := login(t, manager, issuer, "", jwt.MapClaims{"sub": "subject-1", "preferred_username": "alice", "nonce": "forged"})
+	if !errors.Is(err, ErrNonceMismatch) {
+		t.Fatalf("Finish = %v, want ErrNonceMismatch", err)
+	}
+	if userExists(t, storage, "alice") {
+		t.Fatal("account created despite a nonce mismatch")
+	}
+}
+
+func TestPKCEVerifierIsSent(t *testing.T) {
+	issuer := newIssuer(t)
+	manager, storage := newTestManager(t, issuer)
+
+	ctx := context.Background()
+	authorizationURL, stateID, err := manager.Begin(ctx, testProvider, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, code := issuer.authorize(t, authorizationURL, jwt.MapClaims{"sub": "subject-1", "preferred_username": "alice"})
+
+	issuer.mutex.Lock()
+	granted := issuer.grants[code]
+	granted.challenge = base64.RawURLEncoding.EncodeToString(make([]byte, 32))
+	issuer.grants[code] = granted
+	issuer.mutex.Unlock()
+
+	if _, err := manager.Finish(ctx, testProvider, state, stateID, code); err == nil {
+		t.Fatal("Finish succeeded with a code bound to another verifier")
+	}
+	if userExists(t, storage, "alice") {
+		t.Fatal("account created despite a failed code exchange")
+	}
+}
+
+func TestLinkExistingAccount(t *testing.T) {
+	issuer := newIssuer(t)
+	manager, storage := newTestManager(t, issuer)
+	addUser(t, storage, "alice")
+	addUser(t, storage, "bob")
+
+	result, err := login(t, manager, issuer, "alice", jwt.MapClaims{"sub": "subject-1"})
+	if err != nil {
+		t.Fatalf("Finish: %v", err)
+	}
+	if !result.Linking || result.Username != "alice" {
+		t.Fatalf("result = %+v, want alice linked", result)
+	}
+
+	result, err = login(t, manager, issuer, "", jwt.MapClaims{"sub": "subject-1"})
+	if err != nil || result.Username != "alice" {
+		t.Fatalf("login = %+v, %v, want alice", result, err)
+	}
+
+	if _, err := login(t, manager, issuer, "bob", jwt.MapClaims{"sub": "subject-1"}); !errors.Is(err, ErrIdentityLinked) {
+		t.Fatalf("linking to bob = %v, want ErrIdentityLinked", err)
+	}
+
+	ctx := context.Background()
+	if err := manager.Unlink(ctx, "alice", testProvider); err != nil {
+		t.Fatalf("Unlink: %v", err)
+	}
+	identities, err := manager.Identities(ctx, "alice")
+	if err != nil || len(identities) != 0 {
+		t.Fatalf("identities = %+v, %v, want none", identities, err)
+	}
+}
+
+func TestAutoCreateEnforcesUsernamePolicy(t *testing.T) {
+	issuer := newIssuer(t)
+	manager, storage := newTestManager(t, issuer)
+
+	_, err := login(t, manager, issuer, "", jwt.MapClaims{"sub": "subject-1", "preferred_username": "not allowed!"})
+	var validationErrors authentication.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		t.Fatalf("Finish = %v, want validation errors", err)
+	}
+	if userExists(t, storage, "not allowed!") {
+		t.Fatal("account created with an invalid username")
+	}
+}
+
+func TestAutoCreateRejectsTakenUsername(t *testing.T) {
+	issuer := newIssuer(t)
+	manager, storage := newTestManager(t, issuer)
+	addUser(t, storage, "alice")
+
+	if _, err := login(t, manager, issuer, "", jwt.MapClaims{"sub": "subject-1", "preferred_username": "alice"}); !errors.Is(err, ErrUsernameTaken) {
+		t.Fatalf("Finish = %v, want ErrUsernameTaken", err)
+	}
+}
+
+func TestAutoCreateIsAtomic(t *testing.T) {
+	issuer := newIssuer(t)
+	manager, storage := newTestManager(t, issuer)
+
+	err := storage.SQL.ExecuteUpdate(`CREATE TRIGGER reject_identities BEFORE INSERT ON UserIdentities BEGIN SELECT RAISE(ABORT, 'rejected'); END;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := login(t, manager, issuer, "", jwt.MapClaims{"sub": "subject-1", "preferred_username": "alice"}); err == nil {
+		t.Fatal("Finish succeeded although the identity could not be linked")
+	}
+	if userExists(t, storage, "alice") {
+		t.Fatal("orphan account left behind after the identity insert failed")
+	}
+}
+
+func TestUnlinkLastSignInMethod(t *testing.T) {
+	issuer := newIssuer(t)
+	manager, _ := newTestManager(t, issuer)
+
+	if _, err := login(t, manager, issuer, "", jwt.MapClaims{"sub": "subject-1", "preferred_username": "alice"}); err != nil {
+		t.Fatalf("Finish: %v", err)
+	}
+	if err := manager.Unlink(context.Background(), "alice", testProvider); !errors.Is(err, ErrLastSignInMethod) {
+		t.Fatalf("Unlink = %v, want ErrLastSignInMethod", err)
+	}
+}
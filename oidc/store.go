@@ -0,0 +1,165 @@
+package oidc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	sqlstorage "github.com/IzomSoftware/GinWrapper/storage/sql"
+)
+
+const schema = `
+	CREATE TABLE IF NOT EXISTS UserIdentities (
+		provider VARCHAR(255) NOT NULL,
+		subject VARCHAR(255) NOT NULL,
+		username VARCHAR(255) NOT NULL,
+		email VARCHAR(320) NOT NULL DEFAULT '',
+		created_at BIGINT NOT NULL,
+		last_used_at BIGINT NOT NULL,
+		PRIMARY KEY (provider, subject),
+		UNIQUE (provider, username)
+	);
+`
+
+type Identity struct {
+	Provider   string    `json:"provider"`
+	Subject    string    `json:"subject"`
+	Email      string    `json:"email"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+type store struct {
+	sql *sqlstorage.Storage
+}
+
+func (S *store) username(ctx context.Context, provider string, subject string) (string, error) {
+	var username string
+	err := S.sql.QueryRowContext(ctx,
+		"SELECT username FROM UserIdentities WHERE provider = ? AND subject = ?", provider, subject,
+	).Scan(&username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrIdentityNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return username, S.sql.ExecuteUpdateContext(ctx,
+		"UPDATE UserIdentities SET last_used_at = ? WHERE provider = ? AND subject = ?", time.Now().Unix(), provider, subject,
+	)
+}
+
+func (S *store) link(ctx context.Context, provider string, subject string, username string, email string) error {
+	owner, err := S.username(ctx, provider, subject)
+	if err == nil {
+		if owner != username {
+			return ErrIdentityLinked
+		}
+		return nil
+	}
+	if !errors.Is(err, ErrIdentityNotFound) {
+		return err
+	}
+
+	var existing string
+	err = S.sql.QueryRowContext(ctx,
+		"SELECT subject FROM UserIdentities WHERE provider = ? AND username = ?", provider, username,
+	).Scan(&existing)
+	if err == nil {
+		return ErrIdentityLinked
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	now := time.Now().Unix()
+	return S.sql.ExecuteUpdateContext(ctx,
+		"INSERT INTO UserIdentities (provider, subject, username, email, created_at, last_used_at) VALUES (?, ?, ?, ?, ?, ?)",
+		provider, subject, username, email, now, now,
+	)
+}
+
+func (S *store) create(ctx context.Context, provider string, subject string, username string, email string) error {
+	return S.sql.Transaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		var existing string
+		err := tx.QueryRowContext(ctx, "SELECT username FROM Users WHERE username = ?", username).Scan(&existing)
+		if err == nil {
+			return ErrUsernameTaken
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		err = tx.QueryRowContext(ctx, "SELECT username FROM UserIdentities WHERE provider = ? AND subject = ?", provider, subject).Scan(&existing)
+		if err == nil {
+			return ErrIdentityLinked
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "INSERT INTO Users (username, hash) VALUES (?, '')", username); err != nil {
+			return err
+		}
+
+		now := time.Now().Unix()
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO UserIdentities (provider, subject, username, email, created_at, last_used_at) VALUES (?, ?, ?, ?, ?, ?)",
+			provider, subject, username, email, now, now,
+		)
+		return err
+	})
+}
+
+func (S *store) list(ctx context.Context, username string) ([]Identity, error) {
+	rows, err := S.sql.QueryContext(ctx,
+		"SELECT provider, subject, email, created_at, last_used_at FROM UserIdentities WHERE username = ? ORDER BY created_at", username,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []Identity{}
+	for rows.Next() {
+		var identity Identity
+		var createdAt, lastUsedAt int64
+		if err := rows.Scan(&identity.Provider, &identity.Subject, &identity.Email, &createdAt, &lastUsedAt); err != nil {
+			return nil, err
+		}
+		identity.CreatedAt, identity.LastUsedAt = time.Unix(createdAt, 0), time.Unix(lastUsedAt, 0)
+		identities = append(identities, identity)
+	}
+	return identities, rows.Err()
+}
+
+func (S *store) unlink(ctx context.Context, username string, provider string) error {
+	var count int
+	err := S.sql.QueryRowContext(ctx, "SELECT COUNT(*) FROM UserIdentities WHERE username = ?", username).Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	var linked string
+	err = S.sql.QueryRowContext(ctx,
+		"SELECT subject FROM UserIdentities WHERE provider = ? AND username = ?", provider, username,
+	).Scan(&linked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrIdentityNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	var hash string
+	if err := S.sql.QueryRowContext(ctx, "SELECT hash FROM Users WHERE username = ?", username).Scan(&hash); err != nil {
+		return err
+	}
+	if hash == "" && count <= 1 {
+		return ErrLastSignInMethod
+	}
+
+	return S.sql.ExecuteUpdateContext(ctx, "DELETE FROM UserIdentities WHERE provider = ? AND username = ?", provider, username)
+}
@@ -0,0 +1,240 @@
+package passkeys
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/IzomSoftware/GinWrapper/authentication"
+	"github.com/IzomSoftware/GinWrapper/configuration"
+	"github.com/IzomSoftware/GinWrapper/logger"
+	"github.com/IzomSoftware/GinWrapper/storage"
+	"github.com/IzomSoftware/GinWrapper/storage/redis"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+var log = logger.Named("passkeys")
+
+var (
+	ErrPasskeysRequireStorage = fmt.Errorf("passkeys require sql and redis storage")
+	ErrChallengeNotFound      = fmt.Errorf("webauthn challenge not found or expired")
+	ErrChallengeMismatch      = fmt.Errorf("webauthn challenge belongs to another ceremony")
+	ErrPasskeyNotFound        = fmt.Errorf("passkey not found")
+	ErrClonedAuthenticator    = fmt.Errorf("authenticator may be cloned")
+)
+
+const (
+	ceremonyRegistration = "registration"
+	ceremonyLogin        = "login"
+)
+
+type challenge struct {
+	Ceremony string               `json:"ceremony"`
+	Username string               `json:"username,omitempty"`
+	Session  webauthn.SessionData `json:"session"`
+}
+
+type Manager struct {
+	webauthn         *webauthn.WebAuthn
+	store            *store
+	redis            *redis.Storage
+	challengeTTL     time.Duration
+	userVerification protocol.UserVerificationRequirement
+}
+
+func NewManager(storage *storage.Storage, configuration configuration.Passkeys) (*Manager, error) {
+	if storage.SQL == nil || storage.Redis == nil {
+		return nil, ErrPasskeysRequireStorage
+	}
+	if err := storage.SQL.ExecuteUpdate(schema); err != nil {
+		return nil, err
+	}
+
+	userVerification := protocol.UserVerificationRequirement(configuration.UserVerification)
+	webAuthn, err := webauthn.New(&webauthn.Config{
+		RPID:          configuration.RPID,
+		RPDisplayName: configuration.RPDisplayName,
+		RPOrigins:     configuration.RPOrigins,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementPreferred,
+			UserVerification: userVerification,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Manager{
+		webauthn:         webAuthn,
+		store:            &store{sql: storage.SQL},
+		redis:            storage.Redis,
+		challengeTTL:     time.Duration(configuration.ChallengeTTLSeconds) * time.Second,
+		userVerification: userVerification,
+	}, nil
+}
+
+func challengeKey(id string) string {
+	return fmt.Sprintf("webauthn:%s", id)
+}
+
+func (M *Manager) saveChallenge(ctx context.Context, value challenge) (string, error) {
+	id, err := authentication.GenerateRandomSecret(16)
+	if err != nil {
+		return "", err
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return id, M.redis.WithContext(ctx).Set(challengeKey(id), encoded, M.challengeTTL)
+}
+
+func (M *Manager) takeChallenge(ctx context.Context, id string, ceremony string) (*challenge, error) {
+	encoded, err := M.redis.WithContext(ctx).GetDel(challengeKey(id))
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrChallengeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var value challenge
+	if err := json.Unmarshal([]byte(encoded), &value); err != nil {
+		return nil, err
+	}
+	if value.Ceremony != ceremony {
+		return nil, ErrChallengeMismatch
+	}
+	return &value, nil
+}
+
+func (M *Manager) BeginRegistration(ctx context.Context, username string) (*protocol.CredentialCreation, string, error) {
+	account, err := M.store.account(ctx, username, true)
+	if err != nil {
+		return nil, "", err
+	}
+
+	exclusions := make([]protocol.CredentialDescriptor, len(account.credentials))
+	for i, credential := range account.credentials {
+		exclusions[i] = credential.Descriptor()
+	}
+
+	creation, session, err := M.webauthn.BeginRegistration(account, webauthn.WithExclusions(exclusions))
+	if err != nil {
+		return nil, "", err
+	}
+
+	id, err := M.saveChallenge(ctx, challenge{Ceremony: ceremonyRegistration, Username: username, Session: *session})
+	return creation, id, err
+}
+
+func (M *Manager) FinishRegistration(ctx context.Context, username string, challengeID string, name string, request *http.Request) error {
+	value, err := M.takeChallenge(ctx, challengeID, ceremonyRegistration)
+	if err != nil {
+		return err
+	}
+	if value.Username != username {
+		return ErrChallengeMismatch
+	}
+
+	account, err := M.store.account(ctx, username, false)
+	if err != nil {
+		return err
+	}
+
+	credential, err := M.webauthn.FinishRegistration(account, value.Session, request)
+	if err != nil {
+		return err
+	}
+
+	if err := M.store.add(ctx, username, name, credential); err != nil {
+		return err
+	}
+	log.InfoContext(ctx, "passkey registered", "username", username, "credential", encodeID(credential.ID))
+	return nil
+}
+
+func (M *Manager) BeginLogin(ctx context.Context, username string) (*protocol.CredentialAssertion, string, error) {
+	var assertion *protocol.CredentialAssertion
+	var session *webauthn.SessionData
+	var err error
+
+	if username == "" {
+		assertion, session, err = M.webauthn.BeginDiscoverableLogin(webauthn.WithUserVerification(M.userVerification))
+	} else {
+		account, lookupErr := M.store.account(ctx, username, false)
+		if lookupErr != nil || len(account.credentials) == 0 {
+			assertion, session, err = M.webauthn.BeginDiscoverableLogin(webauthn.WithUserVerification(M.userVerification))
+		} else {
+			assertion, session, err = M.webauthn.BeginLogin(account, webauthn.WithUserVerification(M.userVerification))
+		}
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	id, err := M.saveChallenge(ctx, challenge{Ceremony: ceremonyLogin, Session: *session})
+	return assertion, id, err
+}
+
+func (M *Manager) FinishLogin(ctx context.Context, challengeID string, request *http.Request) (string, error) {
+	value, err := M.takeChallenge(ctx, challengeID, ceremonyLogin)
+	if err != nil {
+		return "", err
+	}
+
+	var username string
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		found, err := M.store.usernameForHandle(ctx, userHandle)
+		if err != nil {
+			return nil, err
+		}
+		username = found
+		return M.store.account(ctx, found, false)
+	}
+
+	var credential *webauthn.Credential
+	if len(value.Session.UserID) > 0 {
+		found, err := M.store.usernameForHandle(ctx, value.Session.UserID)
+		if err != nil {
+			return "", err
+		}
+		account, err := M.store.account(ctx, found, false)
+		if err != nil {
+			return "", err
+		}
+		username = found
+		credential, err = M.webauthn.FinishLogin(account, value.Session, request)
+		if err != nil {
+			return "", err
+		}
+	} else if credential, err = M.webauthn.FinishDiscoverableLogin(handler, value.Session, request); err != nil {
+		return "", err
+	}
+
+	if credential.Authenticator.CloneWarning {
+		log.WarnContext(ctx, "passkey clone warning", "username", username, "credential", encodeID(credential.ID))
+		return "", ErrClonedAuthenticator
+	}
+	if err := M.store.update(ctx, credential); err != nil {
+		return "", err
+	}
+	return username, nil
+}
+
+func (M *Manager) VerifiesUser() bool {
+	return M.userVerification == protocol.VerificationRequired
+}
+
+func (M *Manager) List(ctx context.Context, username string) ([]Passkey, error) {
+	return M.store.list(ctx, username)
+}
+
+func (M *Manager) Remove(ctx context.Context, username string, id string) error {
+	return M.store.remove(ctx, username, id)
+}
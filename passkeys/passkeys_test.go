@@ -0,0 +1,332 @@
+package passkeys
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/IzomSoftware/GinWrapper/configuration"
+	"github.com/IzomSoftware/GinWrapper/storage/storagetest"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+)
+
+const (
+	testRPID   = "localhost"
+	testOrigin = "https://localhost"
+)
+
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+)
+
+type authenticator struct {
+	key        *ecdsa.PrivateKey
+	id         []byte
+	handle     []byte
+	signCount  uint32
+	skipVerify bool
+}
+
+func newAuthenticator(t *testing.T) *authenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		t.Fatal(err)
+	}
+	return &authenticator{key: key, id: id}
+}
+
+func (A *authenticator) flags() byte {
+	if A.skipVerify {
+		return flagUserPresent
+	}
+	return flagUserPresent | flagUserVerified
+}
+
+func (A *authenticator) authData(extra byte, attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, A.flags()|extra)
+	data = binary.BigEndian.AppendUint32(data, A.signCount)
+	return append(data, attested...)
+}
+
+func clientData(t *testing.T, ceremony string, challenge protocol.URLEncodedBase64) []byte {
+	t.Helper()
+	encoded, err := json.Marshal(map[string]string{
+		"type":      ceremony,
+		"challenge": challenge.String(),
+		"origin":    testOrigin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encoded
+}
+
+func encode(value []byte) string {
+	return base64.RawURLEncoding.EncodeToString(value)
+}
+
+func request(t *testing.T, body any) *http.Request {
+	t.Helper()
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(encoded))
+}
+
+func (A *authenticator) create(t *testing.T, creation *protocol.CredentialCreation) *http.Request {
+	t.Helper()
+	A.handle = creation.Response.User.ID.(protocol.URLEncodedBase64)
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  1,
+		XCoord: A.key.PublicKey.X.FillBytes(make([]byte, 32)),
+		YCoord: A.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attested := make([]byte, 16)
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(A.id)))
+	attested = append(attested, A.id...)
+	attested = append(attested, publicKey...)
+
+	attestation, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": A.authData(flagAttested, attested),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return request(t, map[string]any{
+		"id":    encode(A.id),
+		"rawId": encode(A.id),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    encode(clientData(t, "webauthn.create", creation.Response.Challenge)),
+			"attestationObject": encode(attestation),
+		},
+	})
+}
+
+func (A *authenticator) get(t *testing.T, assertion *protocol.CredentialAssertion) *http.Request {
+	t.Helper()
+	A.signCount++
+
+	authData := A.authData(0, nil)
+	client := clientData(t, "webauthn.get", assertion.Response.Challenge)
+	clientHash := sha256.Sum256(client)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, A.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return request(t, map[string]any{
+		"id":    encode(A.id),
+		"rawId": encode(A.id),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    encode(client),
+			"authenticatorData": encode(authData),
+			"signature":         encode(signature),
+			"userHandle":        encode(A.handle),
+		},
+	})
+}
+
+func newTestManager(t *testing.T, userVerification string) *Manager {
+	t.Helper()
+	manager, err := NewManager(storagetest.New(t, ""), configuration.Passkeys{
+		Enabled:             true,
+		RPID:                testRPID,
+		RPDisplayName:       "GinWrapper",
+		RPOrigins:           []string{testOrigin},
+		UserVerification:    userVerification,
+		ChallengeTTLSeconds: 60,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return manager
+}
+
+func register(t *testing.T, manager *Manager, authenticator *authenticator, username string) {
+	t.Helper()
+	ctx := context.Background()
+	creation, challengeID, err := manager.BeginRegistration(ctx, username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.FinishRegistration(ctx, username, challengeID, "Key", authenticator.create(t, creation)); err != nil {
+		t.Fatalf("FinishRegistration: %v", err)
+	}
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	manager := newTestManager(t, "preferred")
+	authenticator := newAuthenticator(t)
+	register(t, manager, authenticator, "alice")
+
+	ctx := context.Background()
+	assertion, challengeID, err := manager.BeginLogin(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	username, err := manager.FinishLogin(ctx, challengeID, authenticator.get(t, assertion))
+	if err != nil {
+		t.Fatalf("FinishLogin: %v", err)
+	}
+	if username != "alice" {
+		t.Fatalf("username = %q, want alice", username)
+	}
+
+	registered, err := manager.List(ctx, "alice")
+	if err != nil || len(registered) != 1 {
+		t.Fatalf("List = %v, %v", registered, err)
+	}
+}
+
+func TestDiscoverableLogin(t *testing.T) {
+	manager := newTestManager(t, "preferred")
+	authenticator := newAuthenticator(t)
+	register(t, manager, authenticator, "alice")
+
+	ctx := context.Background()
+	assertion, challengeID, err := manager.BeginLogin(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	username, err := manager.FinishLogin(ctx, challengeID, authenticator.get(t, assertion))
+	if err != nil || username != "alice" {
+		t.Fatalf("FinishLogin = %q, %v", username, err)
+	}
+}
+
+func TestChallengeIsSingleUse(t *testing.T) {
+	manager := newTestManager(t, "preferred")
+	authenticator := newAuthenticator(t)
+	register(t, manager, authenticator, "alice")
+
+	ctx := context.Background()
+	assertion, challengeID, err := manager.BeginLogin(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := []*http.Request{authenticator.get(t, assertion), authenticator.get(t, assertion)}
+	results := make([]error, len(requests))
+	var wait sync.WaitGroup
+	for i, request := range requests {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			_, results[i] = manager.FinishLogin(ctx, challengeID, request)
+		}()
+	}
+	wait.Wait()
+
+	succeeded := 0
+	for _, err := range results {
+		if err == nil {
+			succeeded++
+		} else if !errors.Is(err, ErrChallengeNotFound) {
+			t.Fatalf("FinishLogin: %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("%d logins succeeded with one challenge, want 1", succeeded)
+	}
+}
+
+func TestChallengeCeremonyMismatch(t *testing.T) {
+	manager := newTestManager(t, "preferred")
+	authenticator := newAuthenticator(t)
+
+	ctx := context.Background()
+	creation, challengeID, err := manager.BeginRegistration(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.FinishLogin(ctx, challengeID, authenticator.create(t, creation)); !errors.Is(err, ErrChallengeMismatch) {
+		t.Fatalf("FinishLogin = %v, want ErrChallengeMismatch", err)
+	}
+}
+
+func TestRequiredUserVerification(t *testing.T) {
+	manager := newTestManager(t, "required")
+	if !manager.VerifiesUser() {
+		t.Fatal("VerifiesUser = false with required user verification")
+	}
+	authenticator := newAuthenticator(t)
+	register(t, manager, authenticator, "alice")
+
+	authenticator.skipVerify = true
+	ctx := context.Background()
+	assertion, challengeID, err := manager.BeginLogin(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.FinishLogin(ctx, challengeID, authenticator.get(t, assertion)); err == nil {
+		t.Fatal("FinishLogin accepted an assertion without user verification")
+	}
+
+	if newTestManager(t, "preferred").VerifiesUser() {
+		t.Fatal("VerifiesUser = true with preferred user verification")
+	}
+}
+
+func TestClonedAuthenticator(t *testing.T) {
+	manager := newTestManager(t, "preferred")
+	authenticator := newAuthenticator(t)
+	register(t, manager, authenticator, "alice")
+
+	ctx := context.Background()
+	for range 2 {
+		assertion, challengeID, err := manager.BeginLogin(ctx, "alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := manager.FinishLogin(ctx, challengeID, authenticator.get(t, assertion)); err != nil {
+			t.Fatalf("FinishLogin: %v", err)
+		}
+	}
+
+	authenticator.signCount = 0
+	assertion, challengeID, err := manager.BeginLogin(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.FinishLogin(ctx, challengeID, authenticator.get(t, assertion)); !errors.Is(err, ErrClonedAuthenticator) {
+		t.Fatalf("FinishLogin = %v, want ErrClonedAuthenticator", err)
+	}
+}
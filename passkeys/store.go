@@ -0,0 +1,178 @@
+package passkeys
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/IzomSoftware/GinWrapper/authentication"
+	sqlstorage "github.com/IzomSoftware/GinWrapper/storage/sql"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+const schema = `
+	CREATE TABLE IF NOT EXISTS UserHandles (
+		username VARCHAR(255) PRIMARY KEY,
+		handle VARCHAR(128) NOT NULL UNIQUE
+	);
+	CREATE TABLE IF NOT EXISTS UserPasskeys (
+		id VARCHAR(255) PRIMARY KEY,
+		username VARCHAR(255) NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		credential TEXT NOT NULL,
+		created_at BIGINT NOT NULL,
+		last_used_at BIGINT NOT NULL
+	);
+`
+
+type Passkey struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+type account struct {
+	handle      []byte
+	username    string
+	credentials []webauthn.Credential
+}
+
+func (A *account) WebAuthnID() []byte {
+	return A.handle
+}
+
+func (A *account) WebAuthnName() string {
+	return A.username
+}
+
+func (A *account) WebAuthnDisplayName() string {
+	return A.username
+}
+
+func (A *account) WebAuthnCredentials() []webauthn.Credential {
+	return A.credentials
+}
+
+type store struct {
+	sql *sqlstorage.Storage
+}
+
+func encodeID(id []byte) string {
+	return base64.RawURLEncoding.EncodeToString(id)
+}
+
+func (S *store) handle(ctx context.Context, username string, create bool) ([]byte, error) {
+	var encoded string
+	err := S.sql.QueryRowContext(ctx, "SELECT handle FROM UserHandles WHERE username = ?", username).Scan(&encoded)
+	if errors.Is(err, sql.ErrNoRows) && create {
+		generated, err := authentication.GenerateRandomSecret(32)
+		if err != nil {
+			return nil, err
+		}
+		if err := S.sql.ExecuteUpdateContext(ctx, "INSERT INTO UserHandles (username, handle) VALUES (?, ?)", username, generated); err != nil {
+			return nil, err
+		}
+		return []byte(generated), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(encoded), nil
+}
+
+func (S *store) usernameForHandle(ctx context.Context, handle []byte) (string, error) {
+	var username string
+	err := S.sql.QueryRowContext(ctx, "SELECT username FROM UserHandles WHERE handle = ?", string(handle)).Scan(&username)
+	return username, err
+}
+
+func (S *store) account(ctx context.Context, username string, create bool) (*account, error) {
+	handle, err := S.handle(ctx, username, create)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := S.sql.QueryContext(ctx, "SELECT credential FROM UserPasskeys WHERE username = ?", username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	account := &account{handle: handle, username: username}
+	for rows.Next() {
+		var encoded string
+		if err := rows.Scan(&encoded); err != nil {
+			return nil, err
+		}
+
+		var credential webauthn.Credential
+		if err := json.Unmarshal([]byte(encoded), &credential); err != nil {
+			return nil, err
+		}
+		account.credentials = append(account.credentials, credential)
+	}
+	return account, rows.Err()
+}
+
+func (S *store) add(ctx context.Context, username string, name string, credential *webauthn.Credential) error {
+	encoded, err := json.Marshal(credential)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	return S.sql.ExecuteUpdateContext(ctx,
+		"INSERT INTO UserPasskeys (id, username, name, credential, created_at, last_used_at) VALUES (?, ?, ?, ?, ?, ?)",
+		encodeID(credential.ID), username, name, string(encoded), now, now,
+	)
+}
+
+func (S *store) update(ctx context.Context, credential *webauthn.Credential) error {
+	encoded, err := json.Marshal(credential)
+	if err != nil {
+		return err
+	}
+
+	return S.sql.ExecuteUpdateContext(ctx,
+		"UPDATE UserPasskeys SET credential = ?, last_used_at = ? WHERE id = ?",
+		string(encoded), time.Now().Unix(), encodeID(credential.ID),
+	)
+}
+
+func (S *store) list(ctx context.Context, username string) ([]Passkey, error) {
+	rows, err := S.sql.QueryContext(ctx,
+		"SELECT id, name, created_at, last_used_at FROM UserPasskeys WHERE username = ? ORDER BY created_at", username,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	passkeys := []Passkey{}
+	for rows.Next() {
+		var passkey Passkey
+		var createdAt, lastUsedAt int64
+		if err := rows.Scan(&passkey.ID, &passkey.Name, &createdAt, &lastUsedAt); err != nil {
+			return nil, err
+		}
+		passkey.CreatedAt, passkey.LastUsedAt = time.Unix(createdAt, 0), time.Unix(lastUsedAt, 0)
+		passkeys = append(passkeys, passkey)
+	}
+	return passkeys, rows.Err()
+}
+
+func (S *store) remove(ctx context.Context, username string, id string) error {
+	var owner string
+	err := S.sql.QueryRowContext(ctx, "SELECT username FROM UserPasskeys WHERE id = ?", id).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && owner != username) {
+		return ErrPasskeyNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return S.sql.ExecuteUpdateContext(ctx, "DELETE FROM UserPasskeys WHERE id = ?", id)
+}
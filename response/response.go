@@ -10,7 +10,12 @@ import (
 
 func Abort(c *gin.Context, status int) {
 	c.AbortWithStatus(status)
-	logger.Info("aborted", "ip", c.ClientIP(), "status", status)
+	logger.InfoContext(c.Request.Context(), "aborted", "ip", c.ClientIP(), "status", status)
+}
+
+func AbortWithErrors(c *gin.Context, status int, errors any) {
+	c.AbortWithStatusJSON(status, gin.H{"errors": errors})
+	logger.InfoContext(c.Request.Context(), "aborted", "ip", c.ClientIP(), "status", status)
 }
 
 func AbortForbidden(c *gin.Context) {
@@ -53,3 +58,12 @@ func NoRouteWithProtection(registeredPaths []string, aggressive bool, ban func(s
 		NoRoute(c)
 	}
 }
+
+func HTML(c *gin.Context, status int, name string, data gin.H) {
+	if data == nil {
+		data = gin.H{}
+	}
+	data["CSPNonce"] = c.GetString("csp_nonce")
+	data["CSRFToken"] = c.GetString("csrf_token")
+	c.HTML(status, name, data)
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/IzomSoftware/GinWrapper/logger"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+var ErrACMEStorageRequired = fmt.Errorf("acme requires a configured storage backend")
+
+func (S *Server) acmeManager() (*autocert.Manager, error) {
+	if S.acme != nil {
+		return S.acme, nil
+	}
+
+	acmeConfiguration := S.configuration.HTTPServer.TlsConfiguration.ACME
+	if S.storage == nil {
+		return nil, ErrACMEStorageRequired
+	}
+
+	cache, err := S.storage.CertificateCache(acmeConfiguration.CacheBackend)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := http.DefaultClient
+	if acmeConfiguration.CACertFile != "" {
+		pem, err := os.ReadFile(acmeConfiguration.CACertFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in acme ca file %s", acmeConfiguration.CACertFile)
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+		httpClient = &http.Client{Transport: transport, Timeout: 30 * time.Second}
+	}
+
+	S.acme = &autocert.Manager{
+		Prompt:      autocert.AcceptTOS,
+		Cache:       cache,
+		HostPolicy:  autocert.HostWhitelist(acmeConfiguration.Domains...),
+		Email:       acmeConfiguration.Email,
+		RenewBefore: time.Duration(acmeConfiguration.RenewBeforeDays) * 24 * time.Hour,
+		Client: &acme.Client{
+			DirectoryURL: acmeConfiguration.DirectoryURL,
+			HTTPClient:   httpClient,
+		},
+	}
+	logger.Info("acme enabled", "directory", acmeConfiguration.DirectoryURL, "domains", acmeConfiguration.Domains, "cache", acmeConfiguration.CacheBackend)
+	return S.acme, nil
+}
+
+func withACMEFallback(manager *autocert.Manager, store *certificateStore) func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		certificate, err := manager.GetCertificate(hello)
+		if err == nil || store == nil {
+			return certificate, err
+		}
+		return store.GetCertificate(hello)
+	}
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"os"
+	"testing"
+
+	"github.com/IzomSoftware/GinWrapper/configuration"
+	"github.com/IzomSoftware/GinWrapper/storage/storagetest"
+)
+
+// Run pebble with PEBBLE_VA_ALWAYS_VALID=1, then point PEBBLE_DIRECTORY_URL
+// (for example https://localhost:14000/dir) and PEBBLE_CA_CERT_FILE at it.
+func TestACMEWithPebble(t *testing.T) {
+	directoryURL, caCertFile := os.Getenv("PEBBLE_DIRECTORY_URL"), os.Getenv("PEBBLE_CA_CERT_FILE")
+	if directoryURL == "" || caCertFile == "" {
+		t.Skip("PEBBLE_DIRECTORY_URL and PEBBLE_CA_CERT_FILE are not set")
+	}
+
+	for _, backend := range []string{"sql", "redis"} {
+		t.Run(backend, func(t *testing.T) {
+			config := configuration.Default
+			storagetest.Configure(t, &config)
+			config.HTTPServer.TlsConfiguration.ACME = configuration.ACME{
+				Enabled:         true,
+				DirectoryURL:    directoryURL,
+				Email:           "admin@example.test",
+				Domains:         []string{"example.test"},
+				CacheBackend:    backend,
+				CACertFile:      caCertFile,
+				RenewBeforeDays: 30,
+			}
+
+			server := NewServer(&config, storagetest.Open(t, &config, ""), nil)
+			manager, err := server.acmeManager()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			certificate, err := manager.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.test"})
+			if err != nil {
+				t.Fatalf("GetCertificate: %v", err)
+			}
+			if certificate.Leaf == nil || certificate.Leaf.VerifyHostname("example.test") != nil {
+				t.Fatal("issued certificate does not cover example.test")
+			}
+
+			if _, err := manager.Cache.Get(context.Background(), "example.test+rsa"); err != nil {
+				t.Fatalf("certificate was not stored in the %s cache: %v", backend, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+	"time"
+
+	"github.com/IzomSoftware/GinWrapper/logger"
+	"github.com/IzomSoftware/GinWrapper/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type banRequest struct {
+	IP              string `json:"ip" form:"ip" binding:"required"`
+	DurationSeconds int    `json:"duration_seconds" form:"duration_seconds"`
+	Permanent       bool   `json:"permanent" form:"permanent"`
+}
+
+func (S *Server) setupAdmin() {
+	adminConfiguration := S.configuration.Admin
+	if !adminConfiguration.Enabled {
+		return
+	}
+
+	S.Admin = gin.New()
+	S.Admin.Use(gin.Recovery(), middleware.RequestID(), adminAuthentication(adminConfiguration.Token))
+
+	if adminConfiguration.Pprof {
+		debug := S.Admin.Group("/debug/pprof")
+		debug.GET("/", gin.WrapF(pprof.Index))
+		debug.GET("/cmdline", gin.WrapF(pprof.Cmdline))
+		debug.GET("/profile", gin.WrapF(pprof.Profile))
+		debug.POST("/symbol", gin.WrapF(pprof.Symbol))
+		debug.GET("/symbol", gin.WrapF(pprof.Symbol))
+		debug.GET("/trace", gin.WrapF(pprof.Trace))
+		debug.GET("/:profile", func(c *gin.Context) {
+			pprof.Handler(c.Param("profile")).ServeHTTP(c.Writer, c.Request)
+		})
+	}
+
+	S.Admin.GET("/healthz", S.Health.LivenessHandler())
+	S.Admin.GET("/readyz", S.Health.ReadinessHandler())
+	S.Admin.GET("/log-levels", logger.LevelsHandler())
+	S.Admin.PUT("/log-levels", logger.SetLevelHandler())
+	S.Admin.GET("/config", func(c *gin.Context) {
+		redacted, err := S.configuration.Redacted()
+		if err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.JSON(http.StatusOK, redacted)
+	})
+
+	if S.storage != nil && S.storage.Redis != nil {
+		S.Admin.GET("/bans", func(c *gin.Context) {
+			bans, err := middleware.ListBans(S.storage.Redis.WithContext(c.Request.Context()))
+			if err != nil {
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+			c.JSON(http.StatusOK, bans)
+		})
+		S.Admin.POST("/bans", func(c *gin.Context) {
+			var request banRequest
+			if err := c.ShouldBind(&request); err != nil || net.ParseIP(request.IP) == nil {
+				c.AbortWithStatus(http.StatusBadRequest)
+				return
+			}
+			if request.Permanent == (request.DurationSeconds != 0) || request.DurationSeconds < 0 {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "either a positive duration_seconds or permanent=true is required"})
+				return
+			}
+			duration := time.Duration(request.DurationSeconds) * time.Second
+			if err := middleware.BanIP(S.storage.Redis.WithContext(c.Request.Context()), request.IP, duration); err != nil {
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+			logger.InfoContext(c.Request.Context(), "ip banned by admin", "ip", request.IP, "duration", duration.String())
+			c.Status(http.StatusNoContent)
+		})
+		S.Admin.DELETE("/bans/:ip", func(c *gin.Context) {
+			ip := c.Param("ip")
+			if err := middleware.UnbanIP(S.storage.Redis.WithContext(c.Request.Context()), ip); err != nil {
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+			logger.InfoContext(c.Request.Context(), "ip unbanned by admin", "ip", ip)
+			c.Status(http.StatusNoContent)
+		})
+		S.Admin.GET("/lockouts", func(c *gin.Context) {
+			lockouts, err := middleware.ListLockouts(S.storage.Redis.WithContext(c.Request.Context()))
+			if err != nil {
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+			c.JSON(http.StatusOK, lockouts)
+		})
+		S.Admin.DELETE("/lockouts/:username", func(c *gin.Context) {
+			username := c.Param("username")
+			if err := middleware.UnlockAccount(S.storage.Redis.WithContext(c.Request.Context()), username); err != nil {
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+			logger.InfoContext(c.Request.Context(), "account unlocked by admin", "username", username)
+			c.Status(http.StatusNoContent)
+		})
+	}
+}
+
+func adminAuthentication(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.Next()
+			return
+		}
+
+		provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,26 @@
+package server
+
+import (
+	"context"
+)
+
+func (S *Server) registerHealth() {
+	healthConfiguration := S.configuration.Health
+	if !healthConfiguration.Enabled {
+		return
+	}
+
+	if S.storage != nil && S.storage.SQL != nil {
+		S.Health.Register("sql", 0, func(ctx context.Context) error {
+			return S.storage.SQL.PingContext(ctx)
+		})
+	}
+	if S.storage != nil && S.storage.Redis != nil {
+		S.Health.Register("redis", 0, func(ctx context.Context) error {
+			return S.storage.Redis.WithContext(ctx).Ping()
+		})
+	}
+
+	S.Engine.GET(healthConfiguration.LivenessPath, S.Health.LivenessHandler())
+	S.Engine.GET(healthConfiguration.ReadinessPath, S.Health.ReadinessHandler())
+}
@@ -0,0 +1,217 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/IzomSoftware/GinWrapper/configuration"
+	"github.com/IzomSoftware/GinWrapper/logger"
+	"github.com/quic-go/quic-go/http3"
+)
+
+const (
+	RoleApp      = "app"
+	RoleRedirect = "redirect"
+	RoleAdmin    = "admin"
+)
+
+var ErrInvalidListenerRole = fmt.Errorf("invalid listener role")
+var ErrAdminDisabled = fmt.Errorf("admin listener configured but admin is disabled")
+var ErrNotASocket = fmt.Errorf("unix listener path exists and is not a socket")
+
+type binding struct {
+	name        string
+	listener    net.Listener
+	server      *http.Server
+	tls         bool
+	http3Server *http3.Server
+	packetConn  net.PacketConn
+}
+
+func (B binding) close() {
+	B.listener.Close()
+	if B.packetConn != nil {
+		B.packetConn.Close()
+	}
+}
+
+func listen(network string, address string, permissions uint32) (net.Listener, error) {
+	if network == "" {
+		network = "tcp"
+	}
+
+	if network != "unix" {
+		return net.Listen(network, address)
+	}
+
+	if info, err := os.Lstat(address); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%w: %s", ErrNotASocket, address)
+		}
+		if err := os.Remove(address); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	if permissions != 0 {
+		if err := os.Chmod(address, os.FileMode(permissions)); err != nil {
+			listener.Close()
+			return nil, err
+		}
+	}
+	return listener, nil
+}
+
+func (S *Server) listenerConfigurations() []configuration.Listener {
+	httpServerConfiguration := S.configuration.HTTPServer
+	if len(httpServerConfiguration.Listeners) > 0 {
+		return httpServerConfiguration.Listeners
+	}
+
+	return []configuration.Listener{{
+		Network: "tcp",
+		Address: net.JoinHostPort(httpServerConfiguration.Address, strconv.Itoa(httpServerConfiguration.Port)),
+		Role:    RoleApp,
+		TLS:     httpServerConfiguration.TlsConfiguration.Enable,
+	}}
+}
+
+func (S *Server) hasAdminListener() bool {
+	for _, listenerConfiguration := range S.configuration.HTTPServer.Listeners {
+		if listenerConfiguration.Role == RoleAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+func (S *Server) handlerFor(listenerConfiguration configuration.Listener) (http.Handler, error) {
+	switch listenerConfiguration.Role {
+	case "", RoleApp:
+		return S.Engine, nil
+	case RoleRedirect:
+		if S.configuration.HTTPServer.TlsConfiguration.ACME.Enabled {
+			manager, err := S.acmeManager()
+			if err != nil {
+				return nil, err
+			}
+			return manager.HTTPHandler(redirectHandler(listenerConfiguration.RedirectPort)), nil
+		}
+		return redirectHandler(listenerConfiguration.RedirectPort), nil
+	case RoleAdmin:
+		if S.Admin == nil {
+			return nil, ErrAdminDisabled
+		}
+		return S.Admin, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidListenerRole, listenerConfiguration.Role)
+	}
+}
+
+func redirectHandler(port int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			host = hostname
+		} else {
+			host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+		}
+		if port != 0 && port != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(port))
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+func (S *Server) bind() ([]binding, error) {
+	var bindings []binding
+	closeAll := func() {
+		for _, bound := range bindings {
+			bound.close()
+		}
+	}
+
+	for _, listenerConfiguration := range S.listenerConfigurations() {
+		handler, err := S.handlerFor(listenerConfiguration)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+
+		if listenerConfiguration.H2C && listenerConfiguration.TLS {
+			closeAll()
+			return nil, ErrH2CRequiresPlaintext
+		}
+
+		listener, err := listen(listenerConfiguration.Network, listenerConfiguration.Address, listenerConfiguration.SocketPermissions)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+
+		role := listenerConfiguration.Role
+		if role == "" {
+			role = RoleApp
+		}
+		bound := binding{
+			name:     role,
+			listener: listener,
+			server:   &http.Server{Handler: handler},
+			tls:      listenerConfiguration.TLS,
+		}
+
+		if listenerConfiguration.H2C {
+			bound.server.Protocols = cleartextHTTP2Protocols()
+		}
+
+		if listenerConfiguration.HTTP3 {
+			bound.http3Server, bound.packetConn, err = newHTTP3Server(listenerConfiguration, handler)
+			if err != nil {
+				listener.Close()
+				closeAll()
+				return nil, err
+			}
+			bound.server.Handler = altSvcHandler(bound.http3Server, handler)
+		}
+
+		bindings = append(bindings, bound)
+	}
+
+	if S.Admin != nil && !S.hasAdminListener() {
+		adminConfiguration := S.configuration.Admin
+		network, address := "tcp", net.JoinHostPort(adminConfiguration.Address, strconv.Itoa(adminConfiguration.Port))
+		if adminConfiguration.Socket != "" {
+			network, address = "unix", adminConfiguration.Socket
+		}
+
+		listener, err := listen(network, address, adminConfiguration.SocketPermissions)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		bindings = append(bindings, binding{name: RoleAdmin, listener: listener, server: &http.Server{Handler: S.Admin}})
+	}
+
+	if S.metricsHandler != nil {
+		metricsConfiguration := S.configuration.Metrics
+		listener, err := listen("tcp", net.JoinHostPort(metricsConfiguration.Address, strconv.Itoa(metricsConfiguration.Port)), 0)
+		if err != nil {
+			logger.Error("metrics listener failed, metrics are not served", "err", err)
+			return bindings, nil
+		}
+		bindings = append(bindings, binding{name: "metrics", listener: listener, server: &http.Server{Handler: S.metricsHandler}})
+	}
+
+	return bindings, nil
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/IzomSoftware/GinWrapper/logger"
+	"github.com/IzomSoftware/GinWrapper/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+func (S *Server) EnableMetrics() error {
+	metricsConfiguration := S.configuration.Metrics
+	if !metricsConfiguration.Enabled {
+		return nil
+	}
+	if S.Admin == nil && metricsConfiguration.Port == 0 {
+		logger.Info("metrics disabled, enable the admin listener or set a metrics port to serve them")
+		return nil
+	}
+
+	if S.storage != nil && S.storage.SQL != nil {
+		if err := metrics.RegisterSQL(S.storage.SQL); err != nil {
+			return err
+		}
+	}
+	if S.storage != nil && S.storage.Redis != nil {
+		if err := metrics.RegisterRedis(S.storage.Redis); err != nil {
+			return err
+		}
+	}
+
+	S.Use(metrics.Middleware())
+
+	if S.Admin != nil {
+		S.Admin.GET(metricsConfiguration.Path, gin.WrapH(metrics.Handler()))
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(metricsConfiguration.Path, metrics.Handler())
+	S.metricsHandler = mux
+	return nil
+}
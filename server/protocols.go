@@ -0,0 +1,60 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/IzomSoftware/GinWrapper/configuration"
+	"github.com/quic-go/quic-go/http3"
+)
+
+var ErrHTTP3RequiresTLS = fmt.Errorf("http3 requires a tls listener")
+var ErrH2CRequiresPlaintext = fmt.Errorf("h2c requires a plaintext listener")
+
+func cleartextHTTP2Protocols() *http.Protocols {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+	return protocols
+}
+
+func newHTTP3Server(listenerConfiguration configuration.Listener, handler http.Handler) (*http3.Server, net.PacketConn, error) {
+	if !listenerConfiguration.TLS {
+		return nil, nil, ErrHTTP3RequiresTLS
+	}
+
+	network := "udp"
+	switch listenerConfiguration.Network {
+	case "tcp4":
+		network = "udp4"
+	case "tcp6":
+		network = "udp6"
+	}
+
+	packetConn, err := net.ListenPacket(network, listenerConfiguration.Address)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, port, err := net.SplitHostPort(packetConn.LocalAddr().String())
+	if err != nil {
+		packetConn.Close()
+		return nil, nil, err
+	}
+	portNumber, err := strconv.Atoi(port)
+	if err != nil {
+		packetConn.Close()
+		return nil, nil, err
+	}
+
+	return &http3.Server{Handler: handler, Port: portNumber}, packetConn, nil
+}
+
+func altSvcHandler(http3Server *http3.Server, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http3Server.SetQUICHeaders(w.Header())
+		next.ServeHTTP(w, r)
+	})
+}
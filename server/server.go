@@ -1,29 +1,51 @@
 package server
 
 import (
-	"fmt"
+	"context"
+	"crypto/tls"
+	"html/template"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/IzomSoftware/GinWrapper/authentication"
 	"github.com/IzomSoftware/GinWrapper/configuration"
+	"github.com/IzomSoftware/GinWrapper/health"
 	"github.com/IzomSoftware/GinWrapper/logger"
 	"github.com/IzomSoftware/GinWrapper/storage"
 	"github.com/gin-gonic/gin"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/crypto/acme/autocert"
 )
 
 type Server struct {
-	configuration *configuration.Config
-	storage       *storage.Storage
-	jwtManager    *authentication.JWTManager
-	Engine        *gin.Engine
+	configuration  *configuration.Config
+	storage        *storage.Storage
+	jwtManager     *authentication.JWTManager
+	servers        []*http.Server
+	http3Servers   []*http3.Server
+	acme           *autocert.Manager
+	templateFuncs  template.FuncMap
+	stopWatchers   context.CancelFunc
+	metricsHandler http.Handler
+	Health         *health.Checker
+	Engine         *gin.Engine
+	Admin          *gin.Engine
 }
 
 func NewServer(configuration *configuration.Config, storage *storage.Storage, jwtManager *authentication.JWTManager) *Server {
-	return &Server{
+	server := &Server{
 		configuration: configuration,
 		storage:       storage,
 		jwtManager:    jwtManager,
+		Health:        health.NewChecker(time.Duration(configuration.Health.CheckTimeoutSeconds) * time.Second),
 		Engine:        gin.New(),
 	}
+	server.registerHealth()
+	server.setupAdmin()
+	return server
 }
 
 func (S *Server) Use(handlerfuncs ...gin.HandlerFunc) {
@@ -42,6 +64,16 @@ func (S *Server) RegisterRoute(method string, path string, handler gin.HandlerFu
 	S.Engine.Handle(method, path, handler)
 }
 
+func (S *Server) AddTemplateFuncs(funcs template.FuncMap) {
+	if S.templateFuncs == nil {
+		S.templateFuncs = template.FuncMap{}
+	}
+	for name, fn := range funcs {
+		S.templateFuncs[name] = fn
+	}
+	S.Engine.SetFuncMap(S.templateFuncs)
+}
+
 func (S *Server) LoadTemplates(path string) {
 	S.Engine.LoadHTMLGlob(path)
 }
@@ -62,11 +94,102 @@ func (S *Server) ListenAndServe() error {
 
 	gin.SetMode(gin.ReleaseMode)
 
-	addr := fmt.Sprintf("%s:%d", httpServerConfiguration.Address, httpServerConfiguration.Port)
-	logger.Info("listening", "addr", addr)
+	bindings, err := S.bind()
+	if err != nil {
+		return err
+	}
+
+	var watchersContext context.Context
+	watchersContext, S.stopWatchers = context.WithCancel(context.Background())
+
+	var tlsConfig *tls.Config
+	for _, bound := range bindings {
+		if bound.tls && tlsConfig == nil {
+			if tlsConfig, err = S.setupTLS(watchersContext); err != nil {
+				for _, bound := range bindings {
+					bound.close()
+				}
+				S.stopWatchers()
+				return err
+			}
+		}
+	}
+
+	S.servers = make([]*http.Server, 0, len(bindings))
+	errs := make(chan error, 2*len(bindings))
+	for _, bound := range bindings {
+		S.servers = append(S.servers, bound.server)
+		if bound.http3Server != nil {
+			S.http3Servers = append(S.http3Servers, bound.http3Server)
+			bound.http3Server.TLSConfig = http3.ConfigureTLSConfig(tlsConfig)
+			go func(bound binding) {
+				logger.Info("listening", "role", bound.name, "addr", bound.packetConn.LocalAddr().String(), "protocol", "http3")
+				errs <- bound.http3Server.Serve(bound.packetConn)
+			}(bound)
+		}
+		go func(bound binding) {
+			logger.Info("listening", "role", bound.name, "addr", bound.listener.Addr().String(), "tls", bound.tls)
+			if bound.tls {
+				bound.server.TLSConfig = tlsConfig
+				errs <- bound.server.ServeTLS(bound.listener, "", "")
+				return
+			}
+			errs <- bound.server.Serve(bound.listener)
+		}(bound)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	select {
+	case err := <-errs:
+		if err == http.ErrServerClosed {
+			return nil
+		}
+		logger.Error("listener failed", "err", err)
+		S.Shutdown(context.Background())
+		return err
+	case received := <-signals:
+		logger.Info("shutdown signal received", "signal", received.String())
+	}
+
+	return S.Shutdown(context.Background())
+}
+
+func (S *Server) Shutdown(ctx context.Context) error {
+	httpServerConfiguration := S.configuration.HTTPServer
+	S.Health.SetShuttingDown()
+
+	if drainDelay := time.Duration(httpServerConfiguration.DrainDelaySeconds) * time.Second; drainDelay > 0 {
+		logger.Info("draining before shutdown", "delay", drainDelay.String())
+		select {
+		case <-time.After(drainDelay):
+		case <-ctx.Done():
+		}
+	}
 
-	if httpServerConfiguration.TlsConfiguration.Enable {
-		return S.Engine.RunTLS(addr, httpServerConfiguration.TlsConfiguration.CertFile, httpServerConfiguration.TlsConfiguration.KeyFile)
+	if shutdownTimeout := time.Duration(httpServerConfiguration.ShutdownTimeoutSeconds) * time.Second; shutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, shutdownTimeout)
+		defer cancel()
+	}
+
+	logger.Info("shutting down")
+	if S.stopWatchers != nil {
+		S.stopWatchers()
+	}
+
+	var shutdownErr error
+	for _, server := range S.http3Servers {
+		if err := server.Shutdown(ctx); err != nil {
+			shutdownErr = err
+		}
+	}
+	for _, server := range S.servers {
+		if err := server.Shutdown(ctx); err != nil {
+			shutdownErr = err
+		}
 	}
-	return S.Engine.Run(addr)
+	return shutdownErr
 }
@@ -0,0 +1,261 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IzomSoftware/GinWrapper/configuration"
+	"github.com/IzomSoftware/GinWrapper/logger"
+	"golang.org/x/crypto/acme"
+)
+
+var ErrNoCertificates = fmt.Errorf("no tls certificates configured")
+var ErrUnknownTLSVersion = fmt.Errorf("unknown tls version")
+var ErrUnknownCipherSuite = fmt.Errorf("unknown cipher suite")
+var ErrUnknownCurve = fmt.Errorf("unknown curve")
+var ErrUnknownClientAuth = fmt.Errorf("unknown client auth mode")
+var ErrInvalidClientCA = fmt.Errorf("no certificates found in client ca file")
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var curves = map[string]tls.CurveID{
+	"X25519":         tls.X25519,
+	"P256":           tls.CurveP256,
+	"P384":           tls.CurveP384,
+	"P521":           tls.CurveP521,
+	"X25519MLKEM768": tls.X25519MLKEM768,
+}
+
+var clientAuthModes = map[string]tls.ClientAuthType{
+	"":                   tls.NoClientCert,
+	"none":               tls.NoClientCert,
+	"request":            tls.RequestClientCert,
+	"require_any":        tls.RequireAnyClientCert,
+	"verify_if_given":    tls.VerifyClientCertIfGiven,
+	"require_and_verify": tls.RequireAndVerifyClientCert,
+}
+
+type loadedCertificate struct {
+	source      configuration.TlsCertificate
+	certificate *tls.Certificate
+	modTime     time.Time
+}
+
+type certificateStore struct {
+	mutex        sync.RWMutex
+	certificates []loadedCertificate
+}
+
+func newCertificateStore(sources []configuration.TlsCertificate) (*certificateStore, error) {
+	if len(sources) == 0 {
+		return nil, ErrNoCertificates
+	}
+
+	store := &certificateStore{}
+	for _, source := range sources {
+		loaded, err := loadCertificate(source)
+		if err != nil {
+			return nil, err
+		}
+		store.certificates = append(store.certificates, loaded)
+	}
+	return store, nil
+}
+
+func loadCertificate(source configuration.TlsCertificate) (loadedCertificate, error) {
+	modTime, err := latestModTime(source)
+	if err != nil {
+		return loadedCertificate{}, err
+	}
+
+	certificate, err := tls.LoadX509KeyPair(source.CertFile, source.KeyFile)
+	if err != nil {
+		return loadedCertificate{}, fmt.Errorf("load certificate %s: %w", source.CertFile, err)
+	}
+	return loadedCertificate{source: source, certificate: &certificate, modTime: modTime}, nil
+}
+
+func latestModTime(source configuration.TlsCertificate) (time.Time, error) {
+	certInfo, err := os.Stat(source.CertFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	keyInfo, err := os.Stat(source.KeyFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if keyInfo.ModTime().After(certInfo.ModTime()) {
+		return keyInfo.ModTime(), nil
+	}
+	return certInfo.ModTime(), nil
+}
+
+func (C *certificateStore) reload() {
+	C.mutex.RLock()
+	current := make([]loadedCertificate, len(C.certificates))
+	copy(current, C.certificates)
+	C.mutex.RUnlock()
+
+	changed := false
+	for i, loaded := range current {
+		modTime, err := latestModTime(loaded.source)
+		if err != nil || !modTime.After(loaded.modTime) {
+			continue
+		}
+
+		reloaded, err := loadCertificate(loaded.source)
+		if err != nil {
+			logger.Error("tls certificate reload failed", "cert_file", loaded.source.CertFile, "err", err)
+			continue
+		}
+		current[i] = reloaded
+		changed = true
+		logger.Info("tls certificate reloaded", "cert_file", loaded.source.CertFile)
+	}
+
+	if changed {
+		C.mutex.Lock()
+		C.certificates = current
+		C.mutex.Unlock()
+	}
+}
+
+func (C *certificateStore) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			C.reload()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (C *certificateStore) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	C.mutex.RLock()
+	defer C.mutex.RUnlock()
+
+	for _, loaded := range C.certificates {
+		if hello.SupportsCertificate(loaded.certificate) == nil {
+			return loaded.certificate, nil
+		}
+	}
+	return C.certificates[0].certificate, nil
+}
+
+func certificateSources(tlsConfiguration configuration.TlsConfiguration) []configuration.TlsCertificate {
+	sources := make([]configuration.TlsCertificate, 0, len(tlsConfiguration.Certificates)+1)
+	if tlsConfiguration.CertFile != "" && tlsConfiguration.KeyFile != "" {
+		sources = append(sources, configuration.TlsCertificate{CertFile: tlsConfiguration.CertFile, KeyFile: tlsConfiguration.KeyFile})
+	}
+	return append(sources, tlsConfiguration.Certificates...)
+}
+
+func newTLSConfig(tlsConfiguration configuration.TlsConfiguration, getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: getCertificate,
+	}
+
+	if tlsConfiguration.MinVersion != "" {
+		version, ok := tlsVersions[tlsConfiguration.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownTLSVersion, tlsConfiguration.MinVersion)
+		}
+		config.MinVersion = version
+	}
+
+	if len(tlsConfiguration.CipherSuites) > 0 {
+		available := map[string]uint16{}
+		for _, suite := range tls.CipherSuites() {
+			available[suite.Name] = suite.ID
+		}
+		for _, name := range tlsConfiguration.CipherSuites {
+			id, ok := available[strings.TrimSpace(name)]
+			if !ok {
+				return nil, fmt.Errorf("%w: %q", ErrUnknownCipherSuite, name)
+			}
+			config.CipherSuites = append(config.CipherSuites, id)
+		}
+	}
+
+	for _, name := range tlsConfiguration.Curves {
+		curve, ok := curves[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownCurve, name)
+		}
+		config.CurvePreferences = append(config.CurvePreferences, curve)
+	}
+
+	clientAuth, ok := clientAuthModes[tlsConfiguration.ClientAuth]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownClientAuth, tlsConfiguration.ClientAuth)
+	}
+	config.ClientAuth = clientAuth
+
+	if tlsConfiguration.ClientCAFile != "" {
+		pem, err := os.ReadFile(tlsConfiguration.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidClientCA, tlsConfiguration.ClientCAFile)
+		}
+		config.ClientCAs = pool
+	}
+
+	return config, nil
+}
+
+func (S *Server) setupTLS(ctx context.Context) (*tls.Config, error) {
+	tlsConfiguration := S.configuration.HTTPServer.TlsConfiguration
+
+	var store *certificateStore
+	sources := certificateSources(tlsConfiguration)
+	if !tlsConfiguration.ACME.Enabled || len(tlsConfiguration.Certificates) > 0 {
+		var err error
+		if store, err = newCertificateStore(sources); err != nil {
+			return nil, err
+		}
+	} else if len(sources) > 0 {
+		if loaded, err := newCertificateStore(sources); err == nil {
+			store = loaded
+		} else {
+			logger.Warn("static tls certificate not loaded, relying on acme", "err", err)
+		}
+	}
+
+	if store != nil && tlsConfiguration.ReloadIntervalSeconds > 0 {
+		go store.watch(ctx, time.Duration(tlsConfiguration.ReloadIntervalSeconds)*time.Second)
+	}
+
+	if !tlsConfiguration.ACME.Enabled {
+		return newTLSConfig(tlsConfiguration, store.GetCertificate)
+	}
+
+	manager, err := S.acmeManager()
+	if err != nil {
+		return nil, err
+	}
+	config, err := newTLSConfig(tlsConfiguration, withACMEFallback(manager, store))
+	if err != nil {
+		return nil, err
+	}
+	config.NextProtos = []string{"h2", "http/1.1", acme.ALPNProto}
+	return config, nil
+}
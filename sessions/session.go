@@ -0,0 +1,202 @@
+package sessions
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/IzomSoftware/GinWrapper/storage/redis"
+	"github.com/gin-gonic/gin"
+)
+
+type Session struct {
+	manager *Manager
+	c       *gin.Context
+	id      string
+	values  map[string]string
+}
+
+func (S *Session) storage() *redis.Storage {
+	return S.manager.redis.WithContext(S.c.Request.Context())
+}
+
+func (S *Session) load(id string) error {
+	values, err := S.storage().HGetAll(sessionKey(id))
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	if S.manager.absoluteTimeout > 0 && time.Since(unixTime(values[fieldCreated])) > S.manager.absoluteTimeout {
+		S.id, S.values = id, values
+		return S.Destroy()
+	}
+
+	if err := S.storage().Expire(sessionKey(id), S.manager.idleTimeout); err != nil {
+		return err
+	}
+
+	S.id, S.values = id, values
+	S.manager.writeCookie(S.c, S.manager.sign(id), int(S.manager.idleTimeout.Seconds()))
+	return nil
+}
+
+func (S *Session) create(values map[string]string) error {
+	id, err := newSessionID()
+	if err != nil {
+		return err
+	}
+
+	if values == nil {
+		values = map[string]string{}
+	}
+	values[fieldCreated] = strconv.FormatInt(time.Now().Unix(), 10)
+
+	fields := make(map[string]any, len(values))
+	for key, value := range values {
+		fields[key] = value
+	}
+	if err := S.storage().HSet(sessionKey(id), fields); err != nil {
+		return err
+	}
+	if err := S.storage().Expire(sessionKey(id), S.manager.idleTimeout); err != nil {
+		return err
+	}
+
+	S.id, S.values = id, values
+	S.manager.writeCookie(S.c, S.manager.sign(id), int(S.manager.idleTimeout.Seconds()))
+	return nil
+}
+
+func (S *Session) ID() string {
+	return S.id
+}
+
+func (S *Session) IsNew() bool {
+	return S.id == ""
+}
+
+func (S *Session) Username() string {
+	return S.values[fieldUsername]
+}
+
+func (S *Session) Get(key string) (string, bool) {
+	value, exists := S.values[key]
+	return value, exists
+}
+
+func (S *Session) Set(key string, value string) error {
+	if S.IsNew() {
+		return S.create(map[string]string{key: value})
+	}
+
+	if err := S.storage().HUpdate(sessionKey(S.id), key, value); err != nil {
+		return err
+	}
+	S.values[key] = value
+	return nil
+}
+
+func (S *Session) Delete(key string) error {
+	if S.IsNew() {
+		return nil
+	}
+
+	if err := S.storage().HDel(sessionKey(S.id), key); err != nil {
+		return err
+	}
+	delete(S.values, key)
+	return nil
+}
+
+func (S *Session) AddFlash(message string) error {
+	var flashes []string
+	if encoded, exists := S.values[fieldFlashes]; exists {
+		_ = json.Unmarshal([]byte(encoded), &flashes)
+	}
+
+	encoded, err := json.Marshal(append(flashes, message))
+	if err != nil {
+		return err
+	}
+	return S.Set(fieldFlashes, string(encoded))
+}
+
+func (S *Session) Flashes() ([]string, error) {
+	encoded, exists := S.values[fieldFlashes]
+	if !exists {
+		return nil, nil
+	}
+
+	var flashes []string
+	if err := json.Unmarshal([]byte(encoded), &flashes); err != nil {
+		return nil, err
+	}
+	return flashes, S.Delete(fieldFlashes)
+}
+
+func (S *Session) Regenerate() error {
+	oldID, values := S.id, S.values
+	delete(values, fieldCreated)
+
+	if err := S.create(values); err != nil {
+		return err
+	}
+	if oldID == "" {
+		return nil
+	}
+
+	if username := values[fieldUsername]; username != "" {
+		if err := S.storage().SRem(userIndexKey(username), oldID); err != nil {
+			return err
+		}
+	}
+	return S.storage().Del(sessionKey(oldID))
+}
+
+func (S *Session) Login(username string) error {
+	if previous := S.Username(); previous != "" && previous != username {
+		if err := S.storage().SRem(userIndexKey(previous), S.id); err != nil {
+			return err
+		}
+		S.values = map[string]string{}
+	}
+
+	S.values[fieldUsername] = username
+	if err := S.Regenerate(); err != nil {
+		return err
+	}
+
+	if err := S.storage().SAdd(userIndexKey(username), S.id); err != nil {
+		return err
+	}
+	if S.manager.absoluteTimeout > 0 {
+		if err := S.storage().Expire(userIndexKey(username), S.manager.absoluteTimeout); err != nil {
+			return err
+		}
+	}
+
+	log.InfoContext(S.c.Request.Context(), "session login", "username", username)
+	return nil
+}
+
+func (S *Session) Destroy() error {
+	if S.IsNew() {
+		return nil
+	}
+
+	if username := S.Username(); username != "" {
+		if err := S.storage().SRem(userIndexKey(username), S.id); err != nil {
+			return err
+		}
+	}
+	if err := S.storage().Del(sessionKey(S.id)); err != nil {
+		return err
+	}
+
+	S.id, S.values = "", map[string]string{}
+	S.manager.writeCookie(S.c, "", -1)
+	return nil
+}
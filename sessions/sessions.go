@@ -0,0 +1,193 @@
+package sessions
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IzomSoftware/GinWrapper/authentication"
+	"github.com/IzomSoftware/GinWrapper/configuration"
+	"github.com/IzomSoftware/GinWrapper/logger"
+	"github.com/IzomSoftware/GinWrapper/response"
+	"github.com/IzomSoftware/GinWrapper/storage/redis"
+	"github.com/gin-gonic/gin"
+)
+
+var log = logger.Named("sessions")
+
+const (
+	contextKey    = "session"
+	fieldCreated  = "_created"
+	fieldUsername = "_username"
+	fieldFlashes  = "_flashes"
+)
+
+var (
+	ErrSessionsRequireRedis = fmt.Errorf("sessions require redis")
+	ErrMissingSecret        = fmt.Errorf("sessions require a signing secret")
+	ErrInvalidSameSite      = fmt.Errorf("invalid same_site value")
+)
+
+type Manager struct {
+	redis           *redis.Storage
+	configuration   configuration.Sessions
+	secret          []byte
+	sameSite        http.SameSite
+	idleTimeout     time.Duration
+	absoluteTimeout time.Duration
+}
+
+func NewManager(redis *redis.Storage, configuration configuration.Sessions, fallbackSecret string) (*Manager, error) {
+	if redis == nil {
+		return nil, ErrSessionsRequireRedis
+	}
+
+	secret := configuration.Secret
+	if secret == "" {
+		secret = fallbackSecret
+	}
+	if secret == "" {
+		return nil, ErrMissingSecret
+	}
+
+	sameSite, err := parseSameSite(configuration.SameSite)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Manager{
+		redis:           redis,
+		configuration:   configuration,
+		secret:          authentication.DeriveKey(secret, "sessions"),
+		sameSite:        sameSite,
+		idleTimeout:     time.Duration(configuration.IdleTimeoutSeconds) * time.Second,
+		absoluteTimeout: time.Duration(configuration.AbsoluteTimeoutSeconds) * time.Second,
+	}, nil
+}
+
+func parseSameSite(value string) (http.SameSite, error) {
+	switch strings.ToLower(value) {
+	case "", "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	}
+	return 0, fmt.Errorf("%w: %q", ErrInvalidSameSite, value)
+}
+
+func sessionKey(id string) string {
+	return fmt.Sprintf("session:%s", id)
+}
+
+func userIndexKey(username string) string {
+	return fmt.Sprintf("sessions:user:%s", username)
+}
+
+func (M *Manager) sign(id string) string {
+	mac := hmac.New(sha256.New, M.secret)
+	mac.Write([]byte(id))
+	return id + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (M *Manager) verify(value string) (string, bool) {
+	id, _, ok := strings.Cut(value, ".")
+	if !ok || id == "" {
+		return "", false
+	}
+	return id, hmac.Equal([]byte(value), []byte(M.sign(id)))
+}
+
+func (M *Manager) writeCookie(c *gin.Context, value string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     M.configuration.CookieName,
+		Value:    value,
+		Path:     M.configuration.Path,
+		Domain:   M.configuration.Domain,
+		MaxAge:   maxAge,
+		Secure:   M.configuration.SecureCookie,
+		HttpOnly: true,
+		SameSite: M.sameSite,
+	})
+}
+
+func (M *Manager) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session := &Session{manager: M, c: c, values: map[string]string{}}
+		c.Set(contextKey, session)
+
+		if cookie, err := c.Cookie(M.configuration.CookieName); err == nil {
+			if id, ok := M.verify(cookie); ok {
+				if err := session.load(id); err != nil {
+					log.ErrorContext(c.Request.Context(), "session load failed", "err", err)
+					response.AbortInternalError(c)
+					return
+				}
+			}
+		}
+
+		if session.Username() != "" {
+			c.Request = c.Request.WithContext(logger.WithUsername(c.Request.Context(), session.Username()))
+		}
+		c.Next()
+	}
+}
+
+func (M *Manager) RequireUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session := Get(c)
+		if session == nil || session.Username() == "" {
+			response.AbortUnauthorized(c)
+			return
+		}
+
+		c.Set("uuid", session.Username())
+		c.Set("username", session.Username())
+		c.Set("auth_method", "session")
+		c.Next()
+	}
+}
+
+func (M *Manager) LogoutEverywhere(ctx context.Context, username string) error {
+	redis := M.redis.WithContext(ctx)
+	ids, err := redis.SMembers(userIndexKey(username))
+	if err != nil {
+		return err
+	}
+
+	keys := []string{userIndexKey(username)}
+	for _, id := range ids {
+		keys = append(keys, sessionKey(id))
+	}
+	if err := redis.Del(keys...); err != nil {
+		return err
+	}
+
+	log.InfoContext(ctx, "sessions revoked", "username", username, "count", len(ids))
+	return nil
+}
+
+func Get(c *gin.Context) *Session {
+	value, exists := c.Get(contextKey)
+	if !exists {
+		return nil
+	}
+	session, _ := value.(*Session)
+	return session
+}
+
+func newSessionID() (string, error) {
+	return authentication.GenerateRandomSecret(32)
+}
+
+func unixTime(value string) time.Time {
+	seconds, _ := strconv.ParseInt(value, 10, 64)
+	return time.Unix(seconds, 0)
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/IzomSoftware/GinWrapper/storage/redis"
+	sqlstorage "github.com/IzomSoftware/GinWrapper/storage/sql"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const certificateCacheSchema = `
+	CREATE TABLE IF NOT EXISTS ACMECertificates (
+		name VARCHAR(255) PRIMARY KEY,
+		data BLOB NOT NULL
+	);
+`
+
+var ErrUnknownCacheBackend = fmt.Errorf("unknown certificate cache backend")
+var ErrCacheBackendDisabled = fmt.Errorf("certificate cache backend is not enabled")
+
+type sqlCertificateCache struct {
+	storage *sqlstorage.Storage
+}
+
+func (C *sqlCertificateCache) Get(ctx context.Context, name string) ([]byte, error) {
+	var data []byte
+	err := C.storage.QueryRowContext(ctx, "SELECT data FROM ACMECertificates WHERE name = ?", name).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, autocert.ErrCacheMiss
+	}
+	return data, err
+}
+
+func (C *sqlCertificateCache) Put(ctx context.Context, name string, data []byte) error {
+	return C.storage.ExecuteUpdateContext(ctx, "REPLACE INTO ACMECertificates (name, data) VALUES (?, ?)", name, data)
+}
+
+func (C *sqlCertificateCache) Delete(ctx context.Context, name string) error {
+	return C.storage.ExecuteUpdateContext(ctx, "DELETE FROM ACMECertificates WHERE name = ?", name)
+}
+
+type redisCertificateCache struct {
+	storage *redis.Storage
+}
+
+func certificateKey(name string) string {
+	return fmt.Sprintf("acme:%s", name)
+}
+
+func (C *redisCertificateCache) Get(ctx context.Context, name string) ([]byte, error) {
+	data, err := C.storage.WithContext(ctx).Get(certificateKey(name))
+	if errors.Is(err, redis.Nil) {
+		return nil, autocert.ErrCacheMiss
+	}
+	return []byte(data), err
+}
+
+func (C *redisCertificateCache) Put(ctx context.Context, name string, data []byte) error {
+	return C.storage.WithContext(ctx).Set(certificateKey(name), data, 0)
+}
+
+func (C *redisCertificateCache) Delete(ctx context.Context, name string) error {
+	return C.storage.WithContext(ctx).Del(certificateKey(name))
+}
+
+func (storage *Storage) CertificateCache(backend string) (autocert.Cache, error) {
+	switch backend {
+	case "sql":
+		if storage.SQL == nil {
+			return nil, fmt.Errorf("%w: %s", ErrCacheBackendDisabled, backend)
+		}
+		if err := storage.SQL.ExecuteUpdate(certificateCacheSchema); err != nil {
+			return nil, err
+		}
+		return &sqlCertificateCache{storage: storage.SQL}, nil
+	case "redis":
+		if storage.Redis == nil {
+			return nil, fmt.Errorf("%w: %s", ErrCacheBackendDisabled, backend)
+		}
+		return &redisCertificateCache{storage: storage.Redis}, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCacheBackend, backend)
+	}
+}
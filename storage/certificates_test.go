@@ -0,0 +1,68 @@
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/IzomSoftware/GinWrapper/storage"
+	"github.com/IzomSoftware/GinWrapper/storage/storagetest"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+func TestCertificateCache(t *testing.T) {
+	for _, backend := range []string{"sql", "redis"} {
+		t.Run(backend, func(t *testing.T) {
+			cache, err := storagetest.New(t, "").CertificateCache(backend)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := context.Background()
+			if _, err := cache.Get(ctx, "example.test"); !errors.Is(err, autocert.ErrCacheMiss) {
+				t.Fatalf("Get before Put = %v, want ErrCacheMiss", err)
+			}
+
+			data := []byte("-----BEGIN CERTIFICATE-----\n\x00\xff")
+			if err := cache.Put(ctx, "example.test", data); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			if cached, err := cache.Get(ctx, "example.test"); err != nil || !bytes.Equal(cached, data) {
+				t.Fatalf("Get = %q, %v, want %q", cached, err, data)
+			}
+
+			replaced := []byte("renewed")
+			if err := cache.Put(ctx, "example.test", replaced); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			if cached, err := cache.Get(ctx, "example.test"); err != nil || !bytes.Equal(cached, replaced) {
+				t.Fatalf("Get after renewal = %q, %v, want %q", cached, err, replaced)
+			}
+
+			if err := cache.Delete(ctx, "example.test"); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if _, err := cache.Get(ctx, "example.test"); !errors.Is(err, autocert.ErrCacheMiss) {
+				t.Fatalf("Get after Delete = %v, want ErrCacheMiss", err)
+			}
+			if err := cache.Delete(ctx, "example.test"); err != nil {
+				t.Fatalf("Delete of a missing entry: %v", err)
+			}
+		})
+	}
+}
+
+func TestCertificateCacheBackends(t *testing.T) {
+	store := storagetest.New(t, "")
+	if _, err := store.CertificateCache("disk"); !errors.Is(err, storage.ErrUnknownCacheBackend) {
+		t.Fatalf("CertificateCache(disk) = %v, want ErrUnknownCacheBackend", err)
+	}
+
+	redis := store.Redis
+	store.Redis = nil
+	defer func() { store.Redis = redis }()
+	if _, err := store.CertificateCache("redis"); !errors.Is(err, storage.ErrCacheBackendDisabled) {
+		t.Fatalf("CertificateCache(redis) = %v, want ErrCacheBackendDisabled", err)
+	}
+}
@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/IzomSoftware/GinWrapper/authentication"
+	sqlstorage "github.com/IzomSoftware/GinWrapper/storage/sql"
+)
+
+const emailSchema = `
+	CREATE TABLE IF NOT EXISTS UserEmails (
+		username VARCHAR(255) PRIMARY KEY,
+		email VARCHAR(320) NOT NULL UNIQUE,
+		verified INTEGER NOT NULL DEFAULT 0,
+		verified_at BIGINT NOT NULL DEFAULT 0
+	);
+	CREATE TABLE IF NOT EXISTS PendingEmails (
+		username VARCHAR(255) PRIMARY KEY,
+		email VARCHAR(320) NOT NULL,
+		created_at BIGINT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS UsedEmailTokens (
+		nonce VARCHAR(64) PRIMARY KEY,
+		expires_at BIGINT NOT NULL
+	);
+`
+
+var ErrEmailsRequireSQL = fmt.Errorf("email storage requires sql storage")
+
+type UserEmail struct {
+	Email    string `json:"email,omitempty"`
+	Verified bool   `json:"verified"`
+	Pending  string `json:"pending,omitempty"`
+}
+
+type EmailStore struct {
+	sql *sqlstorage.Storage
+}
+
+func (storage *Storage) Emails() (*EmailStore, error) {
+	if storage.SQL == nil {
+		return nil, ErrEmailsRequireSQL
+	}
+	if err := storage.SQL.ExecuteUpdate(emailSchema); err != nil {
+		return nil, err
+	}
+	return &EmailStore{sql: storage.SQL}, nil
+}
+
+func (E *EmailStore) verifiedEmail(ctx context.Context, username string) (string, error) {
+	var email string
+	err := E.sql.QueryRowContext(ctx, "SELECT email FROM UserEmails WHERE username = ? AND verified = 1", username).Scan(&email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return email, err
+}
+
+func (E *EmailStore) SetPending(ctx context.Context, username string, email string) error {
+	email, err := authentication.NormalizeEmail(email)
+	if err != nil {
+		return err
+	}
+
+	current, err := E.verifiedEmail(ctx, username)
+	if err != nil {
+		return err
+	}
+	if current == email {
+		return E.sql.ExecuteUpdateContext(ctx, "DELETE FROM PendingEmails WHERE username = ?", username)
+	}
+	return E.sql.ExecuteUpdateContext(ctx,
+		"REPLACE INTO PendingEmails (username, email, created_at) VALUES (?, ?, ?)", username, email, time.Now().Unix(),
+	)
+}
+
+func (E *EmailStore) Email(ctx context.Context, username string) (*UserEmail, error) {
+	current, err := E.verifiedEmail(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending string
+	err = E.sql.QueryRowContext(ctx, "SELECT email FROM PendingEmails WHERE username = ?", username).Scan(&pending)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	if current == "" && pending == "" {
+		return nil, authentication.ErrEmailNotFound
+	}
+	return &UserEmail{Email: current, Verified: current != "", Pending: pending}, nil
+}
+
+func (E *EmailStore) UsernameForEmail(ctx context.Context, email string) (string, error) {
+	email, err := authentication.NormalizeEmail(email)
+	if err != nil {
+		return "", authentication.ErrEmailNotFound
+	}
+
+	var username string
+	err = E.sql.QueryRowContext(ctx, "SELECT username FROM UserEmails WHERE email = ? AND verified = 1", email).Scan(&username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", authentication.ErrEmailNotFound
+	}
+	return username, err
+}
+
+func (E *EmailStore) MarkVerified(ctx context.Context, username string, email string) error {
+	email, err := authentication.NormalizeEmail(email)
+	if err != nil {
+		return authentication.ErrEmailNotFound
+	}
+
+	return E.sql.Transaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		var pending string
+		err := tx.QueryRowContext(ctx, "SELECT email FROM PendingEmails WHERE username = ?", username).Scan(&pending)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && pending != email) {
+			return authentication.ErrEmailNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		var owner string
+		err = tx.QueryRowContext(ctx, "SELECT username FROM UserEmails WHERE email = ? AND verified = 1", email).Scan(&owner)
+		if err == nil && owner != username {
+			return authentication.ErrEmailTaken
+		}
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM UserEmails WHERE username = ? OR email = ?", username, email); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO UserEmails (username, email, verified, verified_at) VALUES (?, ?, 1, ?)", username, email, time.Now().Unix(),
+		); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "DELETE FROM PendingEmails WHERE username = ?", username)
+		return err
+	})
+}
+
+func (E *EmailStore) ConsumeToken(ctx context.Context, nonce string, expiresAt time.Time) error {
+	var existing string
+	err := E.sql.QueryRowContext(ctx, "SELECT nonce FROM UsedEmailTokens WHERE nonce = ?", nonce).Scan(&existing)
+	if err == nil {
+		return authentication.ErrTokenAlreadyUsed
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	if err := E.sql.ExecuteUpdateContext(ctx, "DELETE FROM UsedEmailTokens WHERE expires_at < ?", time.Now().Unix()); err != nil {
+		return err
+	}
+	if err := E.sql.ExecuteUpdateContext(ctx, "INSERT INTO UsedEmailTokens (nonce, expires_at) VALUES (?, ?)", nonce, expiresAt.Unix()); err != nil {
+		return authentication.ErrTokenAlreadyUsed
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/IzomSoftware/GinWrapper/authentication"
+	"github.com/IzomSoftware/GinWrapper/storage/storagetest"
+)
+
+func TestConsumeToken(t *testing.T) {
+	emails, err := storagetest.New(t, "").Emails()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour)
+	if err := emails.ConsumeToken(ctx, "first", expiresAt); err != nil {
+		t.Fatalf("ConsumeToken: %v", err)
+	}
+	if err := emails.ConsumeToken(ctx, "first", expiresAt); !errors.Is(err, authentication.ErrTokenAlreadyUsed) {
+		t.Fatalf("second ConsumeToken = %v, want ErrTokenAlreadyUsed", err)
+	}
+	if err := emails.ConsumeToken(ctx, "second", expiresAt); err != nil {
+		t.Fatalf("ConsumeToken of another nonce: %v", err)
+	}
+}
+
+func TestConsumeTokenPrunesExpired(t *testing.T) {
+	emails, err := storagetest.New(t, "").Emails()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if err := emails.ConsumeToken(ctx, "expired", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("ConsumeToken: %v", err)
+	}
+	if err := emails.ConsumeToken(ctx, "fresh", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("ConsumeToken: %v", err)
+	}
+	if err := emails.ConsumeToken(ctx, "expired", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("ConsumeToken after the expired nonce was pruned: %v", err)
+	}
+	if err := emails.ConsumeToken(ctx, "fresh", time.Now().Add(time.Hour)); !errors.Is(err, authentication.ErrTokenAlreadyUsed) {
+		t.Fatalf("ConsumeToken of an unexpired nonce = %v, want ErrTokenAlreadyUsed", err)
+	}
+}
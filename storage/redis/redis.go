@@ -8,6 +8,8 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+var Nil = redis.Nil
+
 type StorageImplementation interface {
 	GetRedisOpts(config *configuration.RedisConfiguration) (*redis.Options, error)
 }
@@ -22,12 +24,29 @@ func New(cfg *configuration.RedisConfiguration, ctx context.Context, impl Storag
 	if err != nil {
 		return nil, err
 	}
+	client := redis.NewClient(opts)
+	client.AddHook(tracingHook{})
 	return &Storage{
-		client: redis.NewClient(opts),
+		client: client,
 		ctx:    ctx,
 	}, nil
 }
 
+func (S *Storage) WithContext(ctx context.Context) *Storage {
+	return &Storage{
+		client: S.client,
+		ctx:    ctx,
+	}
+}
+
+func (S *Storage) Ping() error {
+	return S.client.Ping(S.ctx).Err()
+}
+
+func (S *Storage) PoolStats() *redis.PoolStats {
+	return S.client.PoolStats()
+}
+
 func (S *Storage) Close() error {
 	return S.client.Close()
 }
@@ -40,6 +59,10 @@ func (S *Storage) Get(key string) (string, error) {
 	return S.client.Get(S.ctx, key).Result()
 }
 
+func (S *Storage) GetDel(key string) (string, error) {
+	return S.client.GetDel(S.ctx, key).Result()
+}
+
 func (S *Storage) HSet(key string, values map[string]any) error {
 	return S.client.HSet(S.ctx, key, values).Err()
 }
@@ -56,6 +79,10 @@ func (S *Storage) HGetAll(key string) (map[string]string, error) {
 	return S.client.HGetAll(S.ctx, key).Result()
 }
 
+func (S *Storage) HDel(key string, fields ...string) error {
+	return S.client.HDel(S.ctx, key, fields...).Err()
+}
+
 func (S *Storage) Exists(key string) (bool, error) {
 	count, err := S.client.Exists(S.ctx, key).Result()
 	return count > 0, err
@@ -65,6 +92,18 @@ func (S *Storage) Del(keys ...string) error {
 	return S.client.Del(S.ctx, keys...).Err()
 }
 
+func (S *Storage) SAdd(key string, members ...any) error {
+	return S.client.SAdd(S.ctx, key, members...).Err()
+}
+
+func (S *Storage) SRem(key string, members ...any) error {
+	return S.client.SRem(S.ctx, key, members...).Err()
+}
+
+func (S *Storage) SMembers(key string) ([]string, error) {
+	return S.client.SMembers(S.ctx, key).Result()
+}
+
 func (S *Storage) Incr(key string) (int64, error) {
 	return S.client.Incr(S.ctx, key).Result()
 }
@@ -73,6 +112,19 @@ func (S *Storage) Expire(key string, time time.Duration) error {
 	return S.client.Expire(S.ctx, key, time).Err()
 }
 
+func (S *Storage) TTL(key string) (time.Duration, error) {
+	return S.client.TTL(S.ctx, key).Result()
+}
+
+func (S *Storage) Keys(pattern string) ([]string, error) {
+	var keys []string
+	iterator := S.client.Scan(S.ctx, 0, pattern, 100).Iterator()
+	for iterator.Next(S.ctx) {
+		keys = append(keys, iterator.Val())
+	}
+	return keys, iterator.Err()
+}
+
 func Script(script string) *redis.Script {
 	return redis.NewScript(script)
 }
@@ -0,0 +1,62 @@
+package redis
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/IzomSoftware/GinWrapper/tracing"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type tracingHook struct{}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil && err != redis.Nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (tracingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		ctx, span := tracing.Start(ctx, "redis.dial", attribute.String("server.address", addr))
+		conn, err := next(ctx, network, addr)
+		endSpan(span, err)
+		return conn, err
+	}
+}
+
+func (tracingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := tracing.Start(ctx, "redis."+cmd.Name(),
+			attribute.String("db.system", "redis"),
+			attribute.String("db.operation.name", cmd.Name()),
+		)
+		err := next(ctx, cmd)
+		endSpan(span, err)
+		return err
+	}
+}
+
+func (tracingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		names := make([]string, len(cmds))
+		for i, cmd := range cmds {
+			names[i] = cmd.Name()
+		}
+
+		ctx, span := tracing.Start(ctx, "redis.pipeline",
+			attribute.String("db.system", "redis"),
+			attribute.String("db.operation.name", strings.Join(names, " ")),
+			attribute.Int("db.operation.batch.size", len(cmds)),
+		)
+		err := next(ctx, cmds)
+		endSpan(span, err)
+		return err
+	}
+}
@@ -0,0 +1,229 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/IzomSoftware/GinWrapper/authentication"
+	"github.com/IzomSoftware/GinWrapper/storage/redis"
+	sqlstorage "github.com/IzomSoftware/GinWrapper/storage/sql"
+)
+
+const sessionTrackerSchema = `
+	CREATE TABLE IF NOT EXISTS UserSessions (
+		id VARCHAR(64) PRIMARY KEY,
+		username VARCHAR(255) NOT NULL,
+		device TEXT NOT NULL,
+		user_agent TEXT NOT NULL,
+		ip VARCHAR(64) NOT NULL,
+		created_at BIGINT NOT NULL,
+		last_used_at BIGINT NOT NULL
+	);
+`
+
+var ErrSessionTrackerRequiresSQL = fmt.Errorf("session tracking requires sql storage")
+
+const (
+	sessionCacheTTL      = time.Minute
+	sessionTouchInterval = time.Minute
+	sessionPruneInterval = time.Hour
+	maxTouchedSessions   = 10000
+)
+
+type SessionTracker struct {
+	sql    *sqlstorage.Storage
+	redis  *redis.Storage
+	maxAge time.Duration
+
+	mutex       sync.Mutex
+	lastTouched map[string]time.Time
+	lastPruned  time.Time
+}
+
+func (storage *Storage) SessionTracker(maxAge time.Duration) (*SessionTracker, error) {
+	if storage.SQL == nil {
+		return nil, ErrSessionTrackerRequiresSQL
+	}
+	if err := storage.SQL.ExecuteUpdate(sessionTrackerSchema); err != nil {
+		return nil, err
+	}
+	return &SessionTracker{
+		sql:         storage.SQL,
+		redis:       storage.Redis,
+		maxAge:      maxAge,
+		lastTouched: map[string]time.Time{},
+	}, nil
+}
+
+func sessionCacheKey(id string) string {
+	return fmt.Sprintf("token_session:%s", id)
+}
+
+func (T *SessionTracker) Create(ctx context.Context, username string, device string, userAgent string, ip string) (*authentication.Session, error) {
+	id, err := authentication.GenerateRandomSecret(16)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := T.prune(ctx, now); err != nil {
+		return nil, err
+	}
+
+	err = T.sql.ExecuteUpdateContext(ctx,
+		"INSERT INTO UserSessions (id, username, device, user_agent, ip, created_at, last_used_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		id, username, device, userAgent, ip, now.Unix(), now.Unix(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &authentication.Session{
+		ID:         id,
+		Device:     device,
+		UserAgent:  userAgent,
+		IP:         ip,
+		CreatedAt:  now,
+		LastUsedAt: now,
+	}, nil
+}
+
+func (T *SessionTracker) Validate(ctx context.Context, id string, username string) error {
+	if T.redis != nil {
+		cached, err := T.redis.WithContext(ctx).Get(sessionCacheKey(id))
+		if err == nil && cached == username {
+			return nil
+		}
+	}
+
+	var owner string
+	err := T.sql.QueryRowContext(ctx, "SELECT username FROM UserSessions WHERE id = ?", id).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && owner != username) {
+		return authentication.ErrSessionRevoked
+	}
+	if err != nil {
+		return err
+	}
+
+	if T.redis != nil {
+		_ = T.redis.WithContext(ctx).Set(sessionCacheKey(id), username, sessionCacheTTL)
+	}
+	return nil
+}
+
+func (T *SessionTracker) prune(ctx context.Context, now time.Time) error {
+	if T.maxAge <= 0 {
+		return nil
+	}
+
+	T.mutex.Lock()
+	if now.Sub(T.lastPruned) < sessionPruneInterval {
+		T.mutex.Unlock()
+		return nil
+	}
+	T.lastPruned = now
+	T.mutex.Unlock()
+
+	return T.sql.ExecuteUpdateContext(ctx, "DELETE FROM UserSessions WHERE last_used_at < ?", now.Add(-T.maxAge-sessionTouchInterval).Unix())
+}
+
+func (T *SessionTracker) markTouched(id string, now time.Time) bool {
+	T.mutex.Lock()
+	defer T.mutex.Unlock()
+
+	if last, ok := T.lastTouched[id]; ok && now.Sub(last) < sessionTouchInterval {
+		return false
+	}
+	if len(T.lastTouched) >= maxTouchedSessions {
+		for touchedID, last := range T.lastTouched {
+			if now.Sub(last) >= sessionTouchInterval {
+				delete(T.lastTouched, touchedID)
+			}
+		}
+		if len(T.lastTouched) >= maxTouchedSessions {
+			clear(T.lastTouched)
+		}
+	}
+	T.lastTouched[id] = now
+	return true
+}
+
+func (T *SessionTracker) Touch(ctx context.Context, id string, ip string) error {
+	now := time.Now()
+	if !T.markTouched(id, now) {
+		return nil
+	}
+
+	return T.sql.ExecuteUpdateContext(ctx, "UPDATE UserSessions SET last_used_at = ?, ip = ? WHERE id = ?", now.Unix(), ip, id)
+}
+
+func (T *SessionTracker) List(ctx context.Context, username string) ([]authentication.Session, error) {
+	rows, err := T.sql.QueryContext(ctx,
+		"SELECT id, device, user_agent, ip, created_at, last_used_at FROM UserSessions WHERE username = ? ORDER BY last_used_at DESC",
+		username,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []authentication.Session{}
+	for rows.Next() {
+		var session authentication.Session
+		var createdAt, lastUsedAt int64
+		if err := rows.Scan(&session.ID, &session.Device, &session.UserAgent, &session.IP, &createdAt, &lastUsedAt); err != nil {
+			return nil, err
+		}
+		session.CreatedAt, session.LastUsedAt = time.Unix(createdAt, 0), time.Unix(lastUsedAt, 0)
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+func (T *SessionTracker) Revoke(ctx context.Context, username string, id string) error {
+	var owner string
+	err := T.sql.QueryRowContext(ctx, "SELECT username FROM UserSessions WHERE id = ?", id).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && owner != username) {
+		return authentication.ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := T.sql.ExecuteUpdateContext(ctx, "DELETE FROM UserSessions WHERE id = ?", id); err != nil {
+		return err
+	}
+	T.forget(ctx, id)
+	return nil
+}
+
+func (T *SessionTracker) RevokeAll(ctx context.Context, username string, except string) error {
+	sessions, err := T.List(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if session.ID == except {
+			continue
+		}
+		if err := T.sql.ExecuteUpdateContext(ctx, "DELETE FROM UserSessions WHERE id = ?", session.ID); err != nil {
+			return err
+		}
+		T.forget(ctx, session.ID)
+	}
+	return nil
+}
+
+func (T *SessionTracker) forget(ctx context.Context, id string) {
+	T.mutex.Lock()
+	delete(T.lastTouched, id)
+	T.mutex.Unlock()
+	if T.redis != nil {
+		_ = T.redis.WithContext(ctx).Del(sessionCacheKey(id))
+	}
+}
@@ -1,9 +1,14 @@
 package sql
 
 import (
+	"context"
 	"database/sql"
 
 	"github.com/IzomSoftware/GinWrapper/configuration"
+	"github.com/IzomSoftware/GinWrapper/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type StorageImplementation interface {
@@ -26,13 +31,41 @@ func New(config *configuration.SQLConfiguration, impl StorageImplementation, cre
 	}, nil
 }
 
+func startSpan(ctx context.Context, operation string, query string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "sql."+operation,
+		attribute.String("db.operation.name", operation),
+		attribute.String("db.query.text", query),
+	)
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil && err != sql.ErrNoRows {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
 func (S *Storage) SetupTables() error {
-	_, err := S.pool.Exec(S.CreationSchema)
+	ctx, span := startSpan(context.Background(), "setup", "")
+	_, err := S.pool.ExecContext(ctx, S.CreationSchema)
+	endSpan(span, err)
 	return err
 }
 
 func (S *Storage) Ping() error {
-	return S.pool.Ping()
+	return S.PingContext(context.Background())
+}
+
+func (S *Storage) PingContext(ctx context.Context) error {
+	ctx, span := startSpan(ctx, "ping", "")
+	err := S.pool.PingContext(ctx)
+	endSpan(span, err)
+	return err
+}
+
+func (S *Storage) Stats() sql.DBStats {
+	return S.pool.Stats()
 }
 
 func (S *Storage) Close() error {
@@ -40,23 +73,72 @@ func (S *Storage) Close() error {
 }
 
 func (S *Storage) ExecuteUpdate(query string, args ...any) error {
-	tx, err := S.pool.Begin()
+	return S.ExecuteUpdateContext(context.Background(), query, args...)
+}
+
+func (S *Storage) ExecuteUpdateContext(ctx context.Context, query string, args ...any) error {
+	_, err := S.ExecuteAffectedContext(ctx, query, args...)
+	return err
+}
+
+func (S *Storage) ExecuteAffected(query string, args ...any) (int64, error) {
+	return S.ExecuteAffectedContext(context.Background(), query, args...)
+}
+
+func (S *Storage) ExecuteAffectedContext(ctx context.Context, query string, args ...any) (affected int64, err error) {
+	ctx, span := startSpan(ctx, "exec", query)
+	defer func() { endSpan(span, err) }()
+
+	tx, err := S.pool.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer tx.Rollback()
 
-	_, err = tx.Exec(query, args...)
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	if affected, err = result.RowsAffected(); err != nil {
+		return 0, err
+	}
+	return affected, tx.Commit()
+}
+
+func (S *Storage) Transaction(ctx context.Context, fn func(ctx context.Context, tx *sql.Tx) error) (err error) {
+	ctx, span := startSpan(ctx, "transaction", "")
+	defer func() { endSpan(span, err) }()
+
+	tx, err := S.pool.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+
+	if err = fn(ctx, tx); err != nil {
+		return err
+	}
 	return tx.Commit()
 }
 
 func (S *Storage) QueryRow(query string, args ...any) *sql.Row {
-	return S.pool.QueryRow(query, args...)
+	return S.QueryRowContext(context.Background(), query, args...)
+}
+
+func (S *Storage) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := startSpan(ctx, "query_row", query)
+	row := S.pool.QueryRowContext(ctx, query, args...)
+	endSpan(span, row.Err())
+	return row
 }
 
 func (S *Storage) Query(query string, args ...any) (*sql.Rows, error) {
-	return S.pool.Query(query, args...)
+	return S.QueryContext(context.Background(), query, args...)
+}
+
+func (S *Storage) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := startSpan(ctx, "query", query)
+	rows, err := S.pool.QueryContext(ctx, query, args...)
+	endSpan(span, err)
+	return rows, err
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/IzomSoftware/GinWrapper/configuration"
+	"github.com/IzomSoftware/GinWrapper/logger"
 	"github.com/IzomSoftware/GinWrapper/storage/redis"
 	"github.com/IzomSoftware/GinWrapper/storage/sql"
 )
@@ -14,6 +15,8 @@ type Storage struct {
 	Redis *redis.Storage
 }
 
+var log = logger.Named("storage")
+
 var ErrNoStorageEnabled = fmt.Errorf("no storage backend enabled")
 
 func New(config *configuration.Config, creationSchema string) (*Storage, error) {
@@ -42,12 +45,14 @@ func initSQL(config *configuration.Config, creationSchema string) (*sql.Storage,
 	var err error
 
 	if databaseConfiguration.MySQLConfiguration.Enabled {
+		log.Info("initializing sql storage", "driver", "mysql")
 		storage, err = sql.New(
 			&configuration.SQLConfiguration{MySQLConfiguration: databaseConfiguration.MySQLConfiguration},
 			&sql.MYSQLStorage{},
 			creationSchema,
 		)
 	} else if databaseConfiguration.SQLiteConfiguration.Enabled {
+		log.Info("initializing sql storage", "driver", "sqlite")
 		storage, err = sql.New(
 			&configuration.SQLConfiguration{SQLiteConfiguration: databaseConfiguration.SQLiteConfiguration},
 			&sql.SQLiteStorage{},
@@ -64,6 +69,7 @@ func initSQL(config *configuration.Config, creationSchema string) (*sql.Storage,
 	if err := storage.SetupTables(); err != nil {
 		return nil, err
 	}
+	log.Debug("sql tables ready")
 
 	return storage, nil
 }
@@ -72,6 +78,7 @@ func initRedis(config *configuration.Config, ctx context.Context) (*redis.Storag
 	databaseConfiguration := config.DatabaseConfiguration
 
 	if databaseConfiguration.DedicatedRedisConfiguration.Enabled {
+		log.Info("initializing redis storage", "mode", "dedicated")
 		return redis.New(
 			&configuration.RedisConfiguration{DedicatedRedisConfiguration: databaseConfiguration.DedicatedRedisConfiguration},
 			ctx,
@@ -80,6 +87,7 @@ func initRedis(config *configuration.Config, ctx context.Context) (*redis.Storag
 	}
 
 	if databaseConfiguration.EmbeddedRedisConfiguration.Enabled {
+		log.Info("initializing redis storage", "mode", "embedded")
 		return redis.New(
 			&configuration.RedisConfiguration{EmbeddedRedisConfiguration: databaseConfiguration.EmbeddedRedisConfiguration},
 			ctx,
@@ -0,0 +1,36 @@
+package storagetest
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/IzomSoftware/GinWrapper/configuration"
+	"github.com/IzomSoftware/GinWrapper/storage"
+)
+
+func Configure(t testing.TB, config *configuration.Config) {
+	t.Helper()
+	config.DatabaseConfiguration = configuration.DatabaseConfiguration{}
+	config.DatabaseConfiguration.SQLiteConfiguration = configuration.SQLiteConfiguration{
+		Enabled:          true,
+		DatabaseLocation: filepath.Join(t.TempDir(), "storage.sqlite"),
+	}
+	config.DatabaseConfiguration.EmbeddedRedisConfiguration.Enabled = true
+}
+
+func Open(t testing.TB, config *configuration.Config, creationSchema string) *storage.Storage {
+	t.Helper()
+	store, err := storage.New(config, creationSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func New(t testing.TB, creationSchema string) *storage.Storage {
+	t.Helper()
+	config := &configuration.Config{}
+	Configure(t, config)
+	return Open(t, config, creationSchema)
+}
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/IzomSoftware/GinWrapper/authentication"
+	sqlstorage "github.com/IzomSoftware/GinWrapper/storage/sql"
+)
+
+const twoFactorSchema = `
+	CREATE TABLE IF NOT EXISTS UserTOTP (
+		username VARCHAR(255) PRIMARY KEY,
+		secret VARCHAR(64) NOT NULL,
+		enabled INTEGER NOT NULL DEFAULT 0,
+		last_counter BIGINT NOT NULL DEFAULT 0
+	);
+	CREATE TABLE IF NOT EXISTS UserRecoveryCodes (
+		username VARCHAR(255) NOT NULL,
+		hash TEXT NOT NULL
+	);
+`
+
+var ErrTwoFactorRequiresSQL = fmt.Errorf("two-factor authentication requires sql storage")
+
+type TOTPEnrollment struct {
+	Secret      string
+	Enabled     bool
+	LastCounter int64
+}
+
+type TwoFactorStore struct {
+	sql *sqlstorage.Storage
+}
+
+func (storage *Storage) TwoFactor() (*TwoFactorStore, error) {
+	if storage.SQL == nil {
+		return nil, ErrTwoFactorRequiresSQL
+	}
+	if err := storage.SQL.ExecuteUpdate(twoFactorSchema); err != nil {
+		return nil, err
+	}
+	return &TwoFactorStore{sql: storage.SQL}, nil
+}
+
+func (T *TwoFactorStore) Enrollment(ctx context.Context, username string) (*TOTPEnrollment, error) {
+	var enrollment TOTPEnrollment
+	err := T.sql.QueryRowContext(ctx,
+		"SELECT secret, enabled, last_counter FROM UserTOTP WHERE username = ?", username,
+	).Scan(&enrollment.Secret, &enrollment.Enabled, &enrollment.LastCounter)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, authentication.ErrTOTPNotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &enrollment, nil
+}
+
+func (T *TwoFactorStore) Enabled(ctx context.Context, username string) (bool, error) {
+	enrollment, err := T.Enrollment(ctx, username)
+	if errors.Is(err, authentication.ErrTOTPNotEnrolled) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return enrollment.Enabled, nil
+}
+
+func (T *TwoFactorStore) BeginEnrollment(ctx context.Context, username string, secret string) error {
+	return T.sql.ExecuteUpdateContext(ctx,
+		"REPLACE INTO UserTOTP (username, secret, enabled, last_counter) VALUES (?, ?, 0, 0)", username, secret,
+	)
+}
+
+func (T *TwoFactorStore) ConfirmEnrollment(ctx context.Context, username string, counter int64) error {
+	return T.sql.ExecuteUpdateContext(ctx,
+		"UPDATE UserTOTP SET enabled = 1, last_counter = ? WHERE username = ?", counter, username,
+	)
+}
+
+func (T *TwoFactorStore) Disable(ctx context.Context, username string) error {
+	if err := T.sql.ExecuteUpdateContext(ctx, "DELETE FROM UserTOTP WHERE username = ?", username); err != nil {
+		return err
+	}
+	return T.sql.ExecuteUpdateContext(ctx, "DELETE FROM UserRecoveryCodes WHERE username = ?", username)
+}
+
+func (T *TwoFactorStore) MarkUsed(ctx context.Context, username string, counter int64) error {
+	affected, err := T.sql.ExecuteAffectedContext(ctx,
+		"UPDATE UserTOTP SET last_counter = ? WHERE username = ? AND last_counter < ?", counter, username, counter,
+	)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return authentication.ErrTOTPReplayed
+	}
+	return nil
+}
+
+func (T *TwoFactorStore) ReplaceRecoveryCodes(ctx context.Context, username string, codes []string) error {
+	if err := T.sql.ExecuteUpdateContext(ctx, "DELETE FROM UserRecoveryCodes WHERE username = ?", username); err != nil {
+		return err
+	}
+
+	for _, code := range codes {
+		hash, err := authentication.GenerateHash(code)
+		if err != nil {
+			return err
+		}
+		if err := T.sql.ExecuteUpdateContext(ctx, "INSERT INTO UserRecoveryCodes (username, hash) VALUES (?, ?)", username, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (T *TwoFactorStore) recoveryCodeHashes(ctx context.Context, username string) ([]string, error) {
+	rows, err := T.sql.QueryContext(ctx, "SELECT hash FROM UserRecoveryCodes WHERE username = ?", username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, rows.Err()
+}
+
+func (T *TwoFactorStore) ConsumeRecoveryCode(ctx context.Context, username string, code string) (bool, error) {
+	hashes, err := T.recoveryCodeHashes(ctx, username)
+	if err != nil {
+		return false, err
+	}
+
+	for _, hash := range hashes {
+		if authentication.ValidateHash(hash, code) != nil {
+			continue
+		}
+
+		affected, err := T.sql.ExecuteAffectedContext(ctx, "DELETE FROM UserRecoveryCodes WHERE username = ? AND hash = ?", username, hash)
+		if err != nil {
+			return false, err
+		}
+		return affected > 0, nil
+	}
+	return false, nil
+}
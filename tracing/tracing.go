@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/IzomSoftware/GinWrapper/configuration"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/IzomSoftware/GinWrapper"
+
+var ErrUnknownExporter = fmt.Errorf("unknown tracing exporter")
+
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+func Setup(ctx context.Context, configuration configuration.Tracing) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if !configuration.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := newExporter(ctx, configuration)
+	if err != nil {
+		return nil, err
+	}
+
+	sampler := sdktrace.ParentBased(sdktrace.TraceIDRatioBased(configuration.SampleRatio))
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", configuration.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+func newExporter(ctx context.Context, configuration configuration.Tracing) (sdktrace.SpanExporter, error) {
+	switch strings.ToLower(configuration.Exporter) {
+	case "otlp":
+		options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(configuration.Endpoint)}
+		if configuration.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		if len(configuration.Headers) > 0 {
+			options = append(options, otlptracehttp.WithHeaders(configuration.Headers))
+		}
+		return otlptracehttp.New(ctx, options...)
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownExporter, configuration.Exporter)
+	}
+}